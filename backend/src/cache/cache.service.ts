@@ -9,6 +9,10 @@ export class CacheService implements OnModuleInit, OnModuleDestroy {
   private subscriber: Redis;
   private publisher: Redis;
   private subscriptions: Map<string, Set<(message: string) => void>> = new Map();
+  private patternSubscriptions: Map<
+    string,
+    Set<(channel: string, message: string) => void>
+  > = new Map();
 
   constructor(private readonly configService: ConfigService) {
     const host = this.configService.get<string>('VALKEY_HOST', 'localhost');
@@ -51,6 +55,22 @@ export class CacheService implements OnModuleInit, OnModuleDestroy {
           });
         }
       });
+
+      this.subscriber.on(
+        'pmessage',
+        (pattern: string, channel: string, message: string) => {
+          const handlers = this.patternSubscriptions.get(pattern);
+          if (handlers) {
+            handlers.forEach((handler) => {
+              try {
+                handler(channel, message);
+              } catch (err) {
+                this.logger.error(`Error in subscription handler for ${pattern}:`, err);
+              }
+            });
+          }
+        },
+      );
     } catch (error) {
       this.logger.error('Failed to connect to Valkey', error);
     }
@@ -160,6 +180,17 @@ export class CacheService implements OnModuleInit, OnModuleDestroy {
     }
   }
 
+  async psubscribe(
+    pattern: string,
+    handler: (channel: string, message: string) => void,
+  ): Promise<void> {
+    if (!this.patternSubscriptions.has(pattern)) {
+      this.patternSubscriptions.set(pattern, new Set());
+      await this.subscriber.psubscribe(pattern);
+    }
+    this.patternSubscriptions.get(pattern)!.add(handler);
+  }
+
   getClient(): Redis {
     return this.client;
   }
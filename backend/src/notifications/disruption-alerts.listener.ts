@@ -0,0 +1,90 @@
+import { Injectable, Logger, OnApplicationBootstrap } from '@nestjs/common';
+import { CacheService } from '../cache/cache.service';
+import { QueueService } from '../queue/queue.service';
+
+// Published by the ingestion worker to user:alert:<userId> when a train a
+// user holds a journey on is cancelled, partially cancelled, rescheduled,
+// diverted or short terminated.
+interface JourneyDisruptionAlert {
+  user_id: string;
+  journey_id: string;
+  pnr?: string;
+  train_number: string;
+  disruption: {
+    event_type: string;
+    train_number: string;
+    disruption_type: string;
+    affected_stations: string[];
+    new_departure_time?: string;
+    details: string;
+    affected_journeys: number;
+    timestamp: string;
+  };
+}
+
+const ALERT_PATTERN = 'user:alert:*';
+
+const TITLES: Record<string, string> = {
+  cancelled: 'Train cancelled',
+  partially_cancelled: 'Train partially cancelled',
+  rescheduled: 'Train rescheduled',
+  diverted: 'Train diverted',
+  short_terminated: 'Train short terminated',
+};
+
+@Injectable()
+export class DisruptionAlertsListener implements OnApplicationBootstrap {
+  private readonly logger = new Logger(DisruptionAlertsListener.name);
+
+  constructor(
+    private readonly cacheService: CacheService,
+    private readonly queueService: QueueService,
+  ) {}
+
+  // Subscribes once every module has connected, so the pattern goes out on
+  // the cache's subscriber connection after it is up.
+  async onApplicationBootstrap() {
+    await this.cacheService.psubscribe(ALERT_PATTERN, (channel, message) => {
+      this.handle(channel, message).catch((err) =>
+        this.logger.error(`Failed to queue alert from ${channel}:`, err),
+      );
+    });
+    this.logger.log(
+      `Listening for journey disruption alerts on ${ALERT_PATTERN}`,
+    );
+  }
+
+  async handle(channel: string, message: string): Promise<void> {
+    let alert: JourneyDisruptionAlert;
+    try {
+      alert = JSON.parse(message);
+    } catch {
+      this.logger.warn(`Ignoring malformed alert on ${channel}`);
+      return;
+    }
+    if (!alert.user_id || !alert.disruption) {
+      this.logger.warn(
+        `Ignoring alert without a user or disruption on ${channel}`,
+      );
+      return;
+    }
+
+    const d = alert.disruption;
+    const title = `${TITLES[d.disruption_type] ?? 'Service disruption'}: ${alert.train_number}`;
+    await this.queueService.addNotification(
+      alert.user_id,
+      'service_disruption',
+      title,
+      d.details,
+      {
+        journeyId: alert.journey_id,
+        pnr: alert.pnr,
+        trainNumber: alert.train_number,
+        disruptionType: d.disruption_type,
+        affectedStations: d.affected_stations,
+        newDepartureTime: d.new_departure_time,
+        timestamp: d.timestamp,
+      },
+    );
+  }
+}
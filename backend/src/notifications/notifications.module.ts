@@ -2,6 +2,7 @@ import { Module } from '@nestjs/common';
 import { TypeOrmModule } from '@nestjs/typeorm';
 import { NotificationsController } from './notifications.controller';
 import { NotificationsService } from './notifications.service';
+import { DisruptionAlertsListener } from './disruption-alerts.listener';
 import { UserDevice, NotificationPreference, User } from '../common/entities';
 
 @Module({
@@ -9,7 +10,7 @@ import { UserDevice, NotificationPreference, User } from '../common/entities';
     TypeOrmModule.forFeature([UserDevice, NotificationPreference, User]),
   ],
   controllers: [NotificationsController],
-  providers: [NotificationsService],
+  providers: [NotificationsService, DisruptionAlertsListener],
   exports: [NotificationsService],
 })
 export class NotificationsModule {}
@@ -10,6 +10,7 @@ export interface TrainPositionEvent {
   current_station: string;
   next_station: string;
   eta_next: string;
+  diverted?: boolean;
   timestamp: string;
 }
 
@@ -20,7 +20,12 @@ export interface PnrRefreshJob {
 
 export interface NotificationDispatchJob {
   userId: string;
-  type: 'delay' | 'platform_change' | 'pnr_update' | 'departure_reminder';
+  type:
+    | 'delay'
+    | 'platform_change'
+    | 'pnr_update'
+    | 'departure_reminder'
+    | 'service_disruption';
   title: string;
   body: string;
   data: Record<string, any>;
@@ -136,6 +136,11 @@ export class LiveTrainPositionResponse {
   @ApiProperty()
   etaNext: string;
 
+  @ApiPropertyOptional({
+    description: 'Set when the train was last reported off its route',
+  })
+  diverted?: boolean;
+
   @ApiProperty()
   timestamp: string;
 }
@@ -183,6 +183,7 @@ export class TrainsService {
       currentStation: position.current_station,
       nextStation: position.next_station,
       etaNext: position.eta_next,
+      diverted: position.diverted,
       timestamp: position.timestamp,
     };
 
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS timetable_changes (
+  id SERIAL PRIMARY KEY,
+  train_number VARCHAR(10) REFERENCES trains(number),
+  change_type VARCHAR(20) NOT NULL,
+  station_code VARCHAR(10),
+  stop_number INTEGER,
+  field VARCHAR(50),
+  old_value TEXT,
+  new_value TEXT,
+  source VARCHAR(50),
+  applied_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_timetable_changes_train ON timetable_changes(train_number);
+CREATE INDEX idx_timetable_changes_applied_at ON timetable_changes(applied_at);
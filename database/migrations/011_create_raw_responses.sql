@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS raw_response_bodies (
+  sha256 CHAR(64) PRIMARY KEY,
+  body_gzip BYTEA NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS raw_responses (
+  id BIGSERIAL PRIMARY KEY,
+  train_number VARCHAR(10) NOT NULL,
+  run_date DATE NOT NULL,
+  source VARCHAR(20) NOT NULL,
+  fetched_at TIMESTAMPTZ NOT NULL,
+  sha256 CHAR(64) NOT NULL REFERENCES raw_response_bodies(sha256)
+);
+
+CREATE INDEX idx_raw_responses_fetched_at ON raw_responses(fetched_at);
+CREATE INDEX idx_raw_responses_train ON raw_responses(train_number, fetched_at);
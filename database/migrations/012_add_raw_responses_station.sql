@@ -0,0 +1,2 @@
+-- Station board responses are archived per station rather than per train.
+ALTER TABLE raw_responses ADD COLUMN IF NOT EXISTS station_code VARCHAR(10) NOT NULL DEFAULT '';
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/rail-app/ingestion/internal/admin"
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/leader"
+	"github.com/rail-app/ingestion/internal/publisher"
+	"github.com/rail-app/ingestion/internal/scraper"
+)
+
+// workerStatus is what /status serves.
+type workerStatus struct {
+	Mode    string                `json:"mode"`
+	Role    string                `json:"role"`
+	Started time.Time             `json:"started"`
+	Leader  *leader.Status        `json:"leader_election,omitempty"`
+	Trains  []scraper.TrainStatus `json:"trains,omitempty"`
+}
+
+// startAdmin starts the admin HTTP server, with readiness checks for the
+// worker's dependencies, and returns it with the database handle those
+// checks use. sc is nil in mock mode and elector without leader election.
+// Both results are nil when the server is disabled or cannot listen; the
+// worker runs on without it.
+func startAdmin(cfg *config.Config, pub *publisher.Publisher, sc *scraper.Scraper, elector *leader.Elector) (*admin.Server, *sql.DB) {
+	if cfg.AdminAddr == "" {
+		return nil, nil
+	}
+
+	// A connection of its own, so a check never queues behind scrapes.
+	db, err := sql.Open("postgres", cfg.PostgresDSN())
+	if err != nil {
+		log.Printf("Admin server disabled, failed to open database: %v", err)
+		return nil, nil
+	}
+	db.SetMaxOpenConns(1)
+
+	srv := admin.New(cfg.AdminAddr, cfg.AdminToken)
+	srv.AddCheck("postgres", db.PingContext)
+	srv.AddCheck("valkey", pub.PingValkey)
+	srv.AddCheck("parseable", pub.PingParseable)
+
+	status := workerStatus{Mode: "mock", Started: time.Now()}
+	if sc != nil {
+		status.Mode = "scraper"
+		srv.AddCheck("scraper", func(context.Context) error { return sc.Started() })
+		srv.AddCheck("ntes", func(context.Context) error { return sc.NTESSession() })
+	}
+	srv.SetStatus(func() interface{} {
+		st := status
+		st.Role = leader.RoleLeader
+		if elector != nil {
+			es := elector.Status()
+			st.Role, st.Leader = es.Role, &es
+		}
+		if sc != nil {
+			st.Trains = sc.Status()
+		}
+		return st
+	})
+
+	if err := srv.Start(); err != nil {
+		log.Printf("Admin server disabled: %v", err)
+		db.Close()
+		return nil, nil
+	}
+	return srv, db
+}
@@ -0,0 +1,62 @@
+// Command fakentes serves a local imitation of the NTES mobile site built
+// from the train_routes timetable. Point NTES_BASE_URL at it to run the
+// scraper without internet access.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/fakentes"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	sessionTTL := flag.Duration("session-ttl", 20*time.Minute, "session lifetime (0 = never expire)")
+	rateLimit := flag.Int("rate-limit", 0, "TrainRunning requests per session per minute (0 = unlimited)")
+	malformed := flag.Float64("malformed-rate", 0, "probability of returning a garbled response")
+	baseDelay := flag.Int("base-delay", 5, "delay at the source station in minutes")
+	perStop := flag.Int("delay-per-stop", 3, "extra delay picked up at each stop in minutes")
+	jitter := flag.Int("jitter", 30, "maximum per-run random delay in minutes")
+	seed := flag.Int64("seed", 1, "seed for jitter and malformed responses")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	db, err := sql.Open("postgres", cfg.PostgresDSN())
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	trains, err := fakentes.LoadTrains(db)
+	if err != nil {
+		log.Fatalf("Failed to load timetable: %v", err)
+	}
+
+	srv := fakentes.New(trains, fakentes.Options{
+		SessionTTL:    *sessionTTL,
+		RateLimit:     *rateLimit,
+		MalformedRate: *malformed,
+		Delay: fakentes.DelayModel{
+			BaseMinutes:    *baseDelay,
+			PerStopMinutes: *perStop,
+			JitterMinutes:  *jitter,
+		},
+		Seed: *seed,
+	})
+
+	log.Printf("Fake NTES serving %d trains on %s", len(trains), *addr)
+	if err := http.ListenAndServe(*addr, srv); err != nil {
+		log.Fatalf("Fake NTES server failed: %v", err)
+	}
+}
@@ -2,48 +2,195 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/leader"
+	"github.com/rail-app/ingestion/internal/logging"
 	"github.com/rail-app/ingestion/internal/mockgen"
 	"github.com/rail-app/ingestion/internal/publisher"
+	"github.com/rail-app/ingestion/internal/redact"
 	"github.com/rail-app/ingestion/internal/scraper"
 )
 
 func main() {
-	log.Println("Starting Rail Ingestion Worker...")
+	log.SetOutput(redact.NewWriter(os.Stderr))
+
+	cfg, args, err := config.LoadArgs("ingestion", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+
+	if len(args) > 0 && args[0] == "validate-config" {
+		os.Exit(runValidateConfig(cfg, err, args[1:]))
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	redact.Add(cfg.Secrets()...)
+	logging.SetLevel(cfg.LogLevel)
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "timetable-sync":
+			os.Exit(runTimetableSync(cfg, args[1:]))
+		case "reprocess":
+			os.Exit(runReprocess(cfg, args[1:]))
+		default:
+			log.Fatalf("Unknown command %q", args[0])
+		}
+	}
 
-	cfg := config.Load()
+	log.Println("Starting Rail Ingestion Worker...")
 
 	pub, err := publisher.New(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create publisher: %v", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// ctx stops scheduling new work; work bounds what is already in
+	// flight and is only cancelled if draining overruns ShutdownTimeout.
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	work, abort := context.WithCancel(context.Background())
+	defer abort()
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+
+	// The components that scrape and publish. With leader election they
+	// run only while this replica leads.
+	var components []func(ctx context.Context)
+	var sc *scraper.Scraper
+	reloadables := []reloadable{pub}
 	if cfg.MockData {
 		log.Println("Running in mock data mode")
 		mock := mockgen.New(cfg, pub)
-		go mock.Start(ctx)
+		reloadables = append(reloadables, mock)
+		components = append(components, func(ctx context.Context) { mock.Start(ctx, work) })
 	} else {
 		log.Println("Running in scraper mode")
-		sc := scraper.New(cfg, pub)
-		go sc.Start(ctx)
+		sc = scraper.New(cfg, pub)
+		reloadables = append(reloadables, sc)
+		components = append(components, func(ctx context.Context) { sc.Start(ctx, work) })
+
+		// kill -USR1 dumps per-train scrape state to the log.
+		usr1 := make(chan os.Signal, 1)
+		signal.Notify(usr1, syscall.SIGUSR1)
+		go func() {
+			for range usr1 {
+				sc.DumpStatus(log.Writer())
+			}
+		}()
+
+		if cfg.TimetableSyncInterval > 0 {
+			components = append(components, func(ctx context.Context) { startTimetableSync(ctx, cfg) })
+		}
+	}
+	lead := func(ctx context.Context) {
+		var g sync.WaitGroup
+		for _, start := range components {
+			g.Add(1)
+			go func(start func(context.Context)) {
+				defer g.Done()
+				start(ctx)
+			}(start)
+		}
+		g.Wait()
 	}
 
+	var elector *leader.Elector
+	if cfg.LeaderElection {
+		addr := fmt.Sprintf("%s:%d", cfg.ValkeyHost, cfg.ValkeyPort)
+		elector = leader.New(addr, cfg.LeaderKey, time.Duration(cfg.LeaderTTL)*time.Second)
+		pub.SetFencer(elector)
+		run(func() { elector.Run(ctx, lead) })
+	} else {
+		run(func() { lead(ctx) })
+	}
+
+	adminSrv, adminDB := startAdmin(cfg, pub, sc, elector)
+
+	// kill -HUP re-reads the configuration and applies what can change live.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		running := cfg
+		for range hup {
+			running = reloadConfig(running, reloadables...)
+		}
+	}()
+
 	<-sigCh
-	log.Println("Shutting down ingestion worker...")
-	cancel()
+	timeout := time.Duration(cfg.ShutdownTimeout) * time.Second
+	log.Printf("Shutting down ingestion worker, draining for up to %s...", timeout)
+	stop()
+	if adminSrv != nil {
+		adminSrv.Drain()
+	}
+
+	code := 0
+	if !drain(&wg, timeout, sigCh) {
+		log.Println("Drain did not finish in time, abandoning in-flight work")
+		abort()
+		code = 1
+		// Cancelled work returns quickly; give it a moment to close the
+		// database before the publisher goes away underneath it.
+		drain(&wg, 2*time.Second, nil)
+	}
 
-	time.Sleep(2 * time.Second)
+	if adminSrv != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		adminSrv.Shutdown(shutdownCtx)
+		cancel()
+		adminDB.Close()
+	}
+	if elector != nil {
+		elector.Close()
+	}
+	if err := pub.Close(); err != nil {
+		log.Printf("Failed to close publisher: %v", err)
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
 	log.Println("Ingestion worker stopped")
 }
+
+// drain waits for wg for up to timeout, or until a second signal asks to
+// stop at once. It reports whether everything finished.
+func drain(wg *sync.WaitGroup, timeout time.Duration, sigCh <-chan os.Signal) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+	case <-sigCh:
+		log.Println("Second signal received")
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/logging"
+	"github.com/rail-app/ingestion/internal/redact"
+)
+
+// reloadable is a component that can take a reloaded configuration live.
+type reloadable interface {
+	SetConfig(cfg *config.Config)
+}
+
+// reloadConfig re-reads the configuration from the same file, flags and
+// environment as at startup and hands the settings that can change live to
+// targets. Restart-only settings keep their running values, and an invalid
+// configuration is ignored altogether. It returns the configuration now in
+// effect.
+func reloadConfig(running *config.Config, targets ...reloadable) *config.Config {
+	fresh, _, err := config.LoadArgs("ingestion", os.Args[1:])
+	if err != nil {
+		log.Printf("SIGHUP: configuration not reloaded:\n%v", err)
+		return running
+	}
+
+	next, changed, rejected := config.Reload(running, fresh)
+	for _, name := range rejected {
+		log.Printf("SIGHUP: %s changed but needs a restart to take effect; keeping the running value", name)
+	}
+	if len(changed) == 0 {
+		log.Println("SIGHUP: no reloadable settings changed")
+		return running
+	}
+
+	redact.Add(next.Secrets()...)
+	logging.SetLevel(next.LogLevel)
+	for _, t := range targets {
+		t.SetConfig(next)
+	}
+	log.Printf("SIGHUP: reloaded %s", strings.Join(changed, ", "))
+	return next
+}
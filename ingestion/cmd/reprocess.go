@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/publisher"
+	"github.com/rail-app/ingestion/internal/scraper"
+)
+
+// runReprocess implements the reprocess command: re-parse archived upstream
+// responses for a date range and republish the results to Parseable. They
+// never go to Valkey, where they would overwrite the live state the leader
+// keeps current.
+func runReprocess(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("reprocess", flag.ExitOnError)
+	fromFlag := fs.String("from", time.Now().Format("2006-01-02"), "first fetch date to reprocess (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "last fetch date to reprocess, inclusive (YYYY-MM-DD; default -from)")
+	train := fs.String("train", "", "reprocess a single train instead of all trains")
+	fs.Parse(args)
+
+	from, err := time.Parse("2006-01-02", *fromFlag)
+	if err != nil {
+		log.Printf("Invalid -from date %q: %v", *fromFlag, err)
+		return 2
+	}
+	to := from
+	if *toFlag != "" {
+		if to, err = time.Parse("2006-01-02", *toFlag); err != nil {
+			log.Printf("Invalid -to date %q: %v", *toFlag, err)
+			return 2
+		}
+	}
+	if to.Before(from) {
+		log.Printf("-to %s is before -from %s", *toFlag, *fromFlag)
+		return 2
+	}
+
+	parseableOnly := *cfg
+	parseableOnly.SinkValkey = false
+	pub, err := publisher.New(&parseableOnly)
+	if err != nil {
+		log.Printf("Failed to create publisher: %v", err)
+		return 1
+	}
+	defer pub.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	n, err := scraper.New(&parseableOnly, pub).Reprocess(ctx, from, to.AddDate(0, 0, 1), *train)
+	if err != nil {
+		log.Printf("Reprocess failed: %v", err)
+		return 1
+	}
+	log.Printf("Reprocess complete: %d response(s) republished", n)
+	return 0
+}
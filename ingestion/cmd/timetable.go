@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/timetable"
+)
+
+// timetableSyncPause spaces out upstream schedule requests.
+const timetableSyncPause = 2 * time.Second
+
+// runTimetableSync implements the timetable-sync command.
+func runTimetableSync(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("timetable-sync", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", cfg.TimetableSyncDryRun, "print the diff without changing the database")
+	train := fs.String("train", "", "sync a single train instead of all trains")
+	fs.Parse(args)
+
+	db, err := sql.Open("postgres", cfg.PostgresDSN())
+	if err != nil {
+		log.Printf("Failed to open database: %v", err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	syncer := timetable.NewSyncer(db, timetable.NewERailSource(), *dryRun, os.Stdout)
+
+	if *train != "" {
+		if _, err := syncer.SyncTrain(ctx, *train); err != nil {
+			log.Printf("Timetable sync failed for %s: %v", *train, err)
+			return 1
+		}
+		return 0
+	}
+
+	changed, err := syncer.SyncAll(ctx, timetableSyncPause)
+	if err != nil {
+		log.Printf("Timetable sync failed: %v", err)
+		return 1
+	}
+	log.Printf("Timetable sync complete: %d train(s) changed", changed)
+	return 0
+}
+
+// startTimetableSync runs the periodic timetable job alongside the scraper.
+func startTimetableSync(ctx context.Context, cfg *config.Config) {
+	db, err := sql.Open("postgres", cfg.PostgresDSN())
+	if err != nil {
+		log.Printf("Timetable sync disabled, failed to open database: %v", err)
+		return
+	}
+	defer db.Close()
+
+	syncer := timetable.NewSyncer(db, timetable.NewERailSource(), cfg.TimetableSyncDryRun, os.Stdout)
+	syncer.Run(ctx, time.Duration(cfg.TimetableSyncInterval)*time.Hour, timetableSyncPause)
+}
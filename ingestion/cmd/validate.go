@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rail-app/ingestion/internal/config"
+)
+
+// runValidateConfig implements the validate-config command: report every
+// configuration problem, or print the effective configuration with secrets
+// masked. loadErr is what loading the configuration returned.
+func runValidateConfig(cfg *config.Config, loadErr error, args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	quiet := fs.Bool("q", false, "only report problems")
+	fs.Parse(args)
+
+	if loadErr != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", loadErr)
+		return 1
+	}
+	if !*quiet {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+			return 1
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Configuration OK")
+	return 0
+}
@@ -5,6 +5,7 @@ go 1.21
 require (
 	github.com/lib/pq v1.10.9
 	github.com/redis/go-redis/v9 v9.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,198 @@
+// Package admin serves the worker's operational HTTP endpoints: liveness,
+// readiness, Prometheus metrics, a JSON status dump and, behind a token,
+// pprof.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/metrics"
+)
+
+// checkTimeout bounds each readiness check.
+const checkTimeout = 3 * time.Second
+
+var readyGauge = metrics.NewGaugeVec("ingestion_ready",
+	"Whether the last readiness check of a dependency passed (1) or failed (0).", "check")
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Server is the admin HTTP server.
+type Server struct {
+	srv      *http.Server
+	token    string
+	checks   []namedCheck
+	status   func() interface{}
+	draining atomic.Bool
+}
+
+// New returns a server for addr. pprof is served only when token is set,
+// and only to requests carrying it as a bearer token.
+func New(addr, token string) *Server {
+	s := &Server{token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/status", s.statusz)
+
+	debug := http.NewServeMux()
+	debug.HandleFunc("/debug/pprof/", pprof.Index)
+	debug.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	debug.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	debug.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	debug.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/pprof/", s.guard(debug))
+
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// AddCheck adds a readiness check. Checks must be added before Start.
+func (s *Server) AddCheck(name string, check Check) {
+	s.checks = append(s.checks, namedCheck{name, check})
+}
+
+// SetStatus sets the function whose result /status serves as JSON. It must
+// be called before Start.
+func (s *Server) SetStatus(fn func() interface{}) {
+	s.status = fn
+}
+
+// Start listens on the server's address and serves in the background. It
+// returns the listen error, if any.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("Admin server listening on %s", ln.Addr())
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Admin server failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Drain makes /readyz fail so load balancers and orchestrators stop
+// counting on the worker while it shuts down. Liveness is unaffected.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// Shutdown stops the server, waiting for requests in progress until ctx
+// is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// readyz runs every check concurrently and answers 503 if any fails.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	type result struct {
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+	}
+	results := make(map[string]result, len(s.checks))
+	ready := !s.draining.Load()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range s.checks {
+		wg.Add(1)
+		go func(c namedCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+			defer cancel()
+			err := c.check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				results[c.name] = result{Status: "fail", Error: err.Error()}
+				readyGauge.With(c.name).Set(0)
+				ready = false
+				return
+			}
+			results[c.name] = result{Status: "ok"}
+			readyGauge.With(c.name).Set(1)
+		}(c)
+	}
+	wg.Wait()
+
+	body := struct {
+		Status string            `json:"status"`
+		Checks map[string]result `json:"checks"`
+	}{Status: "ready", Checks: results}
+	code := http.StatusOK
+	if s.draining.Load() {
+		body.Status = "draining"
+		code = http.StatusServiceUnavailable
+	} else if !ready {
+		body.Status = "not ready"
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, body)
+}
+
+func (s *Server) statusz(w http.ResponseWriter, r *http.Request) {
+	if s.status == nil {
+		writeJSON(w, http.StatusOK, struct{}{})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+// guard passes requests carrying the admin token to h and hides h from
+// everyone else.
+func (s *Server) guard(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token == "" {
+			http.NotFound(w, r)
+			return
+		}
+		got := []byte(r.Header.Get("Authorization"))
+		want := []byte("Bearer " + s.token)
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Admin response encoding failed: %v", err)
+	}
+}
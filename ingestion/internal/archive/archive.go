@@ -0,0 +1,83 @@
+// Package archive keeps the raw upstream responses the scraper parsed, so a
+// reported bad delay can be traced to what NTES or eRail actually returned
+// and the parsers can be re-run over past data.
+//
+// Bodies are content-addressed by SHA-256 and stored gzip-compressed, so the
+// many identical "yet to start" pages cost one copy.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+)
+
+type Record struct {
+	TrainNumber string    `json:"train_number"`
+	StationCode string    `json:"station_code,omitempty"` // set instead of TrainNumber for station boards
+	RunDate     string    `json:"run_date"`               // YYYY-MM-DD
+	Source      string    `json:"source"`                 // "ntes", "erail" or "ntes_station_board"
+	FetchedAt   time.Time `json:"fetched_at"`
+	SHA256      string    `json:"sha256"`
+	Body        []byte    `json:"-"`
+}
+
+type Store interface {
+	// Put archives rec.Body and fills in rec.SHA256.
+	Put(ctx context.Context, rec *Record) error
+	// List returns records fetched in [from, to), oldest first, with bodies.
+	// An empty trainNumber matches every train.
+	List(ctx context.Context, from, to time.Time, trainNumber string) ([]Record, error)
+	// Prune deletes records fetched before cutoff and any bodies no longer
+	// referenced, returning the number of records removed.
+	Prune(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// Open returns the store selected by ARCHIVE_BACKEND, or nil when archiving
+// is disabled. db is only used by the postgres backend.
+func Open(cfg *config.Config, db *sql.DB) (Store, error) {
+	switch cfg.ArchiveBackend {
+	case "", "none":
+		return nil, nil
+	case "disk":
+		return NewDiskStore(cfg.ArchiveDir)
+	case "postgres":
+		return NewPostgresStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown archive backend %q", cfg.ArchiveBackend)
+	}
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func compress(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
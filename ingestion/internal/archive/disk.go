@@ -0,0 +1,210 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskStore lays the archive out as
+//
+//	<dir>/objects/ab/abcdef....gz   one gzip body per SHA-256
+//	<dir>/index/2026-10-19.jsonl    one Record per line, by fetch date
+type DiskStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewDiskStore(dir string) (*DiskStore, error) {
+	for _, sub := range []string{"objects", "index"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("create archive dir: %w", err)
+		}
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+func (d *DiskStore) Put(ctx context.Context, rec *Record) error {
+	rec.SHA256 = digest(rec.Body)
+
+	// Held across the object write too, so Prune cannot collect an object
+	// between it being written and indexed.
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	obj := d.objectPath(rec.SHA256)
+	if _, err := os.Stat(obj); errors.Is(err, fs.ErrNotExist) {
+		data, err := compress(rec.Body)
+		if err != nil {
+			return fmt.Errorf("compress body: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(obj), 0o755); err != nil {
+			return err
+		}
+		// Write then rename so a crash never leaves a truncated object
+		// under a valid hash.
+		tmp := obj + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return fmt.Errorf("write object: %w", err)
+		}
+		if err := os.Rename(tmp, obj); err != nil {
+			return fmt.Errorf("commit object: %w", err)
+		}
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(d.indexPath(rec.FetchedAt), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open index: %w", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (d *DiskStore) List(ctx context.Context, from, to time.Time, trainNumber string) ([]Record, error) {
+	d.mu.Lock()
+	files, err := d.indexFiles()
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Record
+	for _, name := range files {
+		day, err := time.Parse("2006-01-02", strings.TrimSuffix(name, ".jsonl"))
+		if err != nil || day.Before(truncateDay(from)) || !day.Before(to) {
+			continue
+		}
+
+		recs, err := d.readIndex(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range recs {
+			if rec.FetchedAt.Before(from) || !rec.FetchedAt.Before(to) {
+				continue
+			}
+			if trainNumber != "" && rec.TrainNumber != trainNumber {
+				continue
+			}
+			data, err := os.ReadFile(d.objectPath(rec.SHA256))
+			if err != nil {
+				return nil, fmt.Errorf("read object %s: %w", rec.SHA256, err)
+			}
+			if rec.Body, err = decompress(data); err != nil {
+				return nil, fmt.Errorf("decompress object %s: %w", rec.SHA256, err)
+			}
+			out = append(out, rec)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].FetchedAt.Before(out[j].FetchedAt) })
+	return out, nil
+}
+
+func (d *DiskStore) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	files, err := d.indexFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	live := make(map[string]bool)
+	for _, name := range files {
+		day, err := time.Parse("2006-01-02", strings.TrimSuffix(name, ".jsonl"))
+		if err != nil {
+			continue
+		}
+		recs, err := d.readIndex(name)
+		if err != nil {
+			return removed, err
+		}
+		// Index files hold whole days; a day is dropped once all of it is
+		// past the cutoff.
+		if !day.AddDate(0, 0, 1).After(cutoff) {
+			if err := os.Remove(filepath.Join(d.dir, "index", name)); err != nil {
+				return removed, err
+			}
+			removed += len(recs)
+			continue
+		}
+		for _, rec := range recs {
+			live[rec.SHA256] = true
+		}
+	}
+
+	err = filepath.WalkDir(filepath.Join(d.dir, "objects"), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if !live[strings.TrimSuffix(entry.Name(), ".gz")] {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func (d *DiskStore) readIndex(name string) ([]Record, error) {
+	f, err := os.Open(filepath.Join(d.dir, "index", name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var recs []Record
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	return recs, sc.Err()
+}
+
+func (d *DiskStore) indexFiles() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(d.dir, "index"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".jsonl") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d *DiskStore) objectPath(sha string) string {
+	return filepath.Join(d.dir, "objects", sha[:2], sha+".gz")
+}
+
+func (d *DiskStore) indexPath(t time.Time) string {
+	return filepath.Join(d.dir, "index", t.UTC().Format("2006-01-02")+".jsonl")
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,102 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresStore keeps bodies in raw_response_bodies keyed by hash and one
+// raw_responses row per fetch.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (p *PostgresStore) Put(ctx context.Context, rec *Record) error {
+	rec.SHA256 = digest(rec.Body)
+
+	data, err := compress(rec.Body)
+	if err != nil {
+		return fmt.Errorf("compress body: %w", err)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO raw_response_bodies (sha256, body_gzip)
+		VALUES ($1, $2)
+		ON CONFLICT (sha256) DO NOTHING
+	`, rec.SHA256, data); err != nil {
+		return fmt.Errorf("insert body: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO raw_responses (train_number, station_code, run_date, source, fetched_at, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, rec.TrainNumber, rec.StationCode, rec.RunDate, rec.Source, rec.FetchedAt, rec.SHA256); err != nil {
+		return fmt.Errorf("insert record: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (p *PostgresStore) List(ctx context.Context, from, to time.Time, trainNumber string) ([]Record, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT r.train_number, r.station_code, to_char(r.run_date, 'YYYY-MM-DD'), r.source, r.fetched_at, r.sha256, b.body_gzip
+		FROM raw_responses r
+		JOIN raw_response_bodies b ON b.sha256 = r.sha256
+		WHERE r.fetched_at >= $1 AND r.fetched_at < $2
+		  AND ($3 = '' OR r.train_number = $3)
+		ORDER BY r.fetched_at ASC, r.id ASC
+	`, from, to, trainNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Record
+	for rows.Next() {
+		var rec Record
+		var data []byte
+		if err := rows.Scan(&rec.TrainNumber, &rec.StationCode, &rec.RunDate, &rec.Source, &rec.FetchedAt, &rec.SHA256, &data); err != nil {
+			return nil, err
+		}
+		if rec.Body, err = decompress(data); err != nil {
+			return nil, fmt.Errorf("decompress %s: %w", rec.SHA256, err)
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func (p *PostgresStore) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM raw_responses WHERE fetched_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("delete records: %w", err)
+	}
+	removed, _ := res.RowsAffected()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM raw_response_bodies b
+		WHERE NOT EXISTS (SELECT 1 FROM raw_responses r WHERE r.sha256 = b.sha256)
+	`); err != nil {
+		return 0, fmt.Errorf("delete orphaned bodies: %w", err)
+	}
+
+	return int(removed), tx.Commit()
+}
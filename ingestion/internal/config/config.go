@@ -1,65 +1,222 @@
 package config
 
 import (
-	"os"
-	"strconv"
+	"fmt"
 )
 
+// Config is the worker's configuration. Each setting can come from the
+// YAML config file (yaml key), a command-line flag (the yaml key with
+// dashes) or an environment variable (env); see Load for precedence.
+// Settings tagged secret are masked when the configuration is printed, and
+// those tagged reload can be changed without a restart (see Reload).
 type Config struct {
-	ParseableURL      string
-	ParseableUser     string
-	ParseablePassword string
-	ValkeyHost        string
-	ValkeyPort        int
-	NTESBaseURL       string
-	PollInterval      int
-	MockData          bool
-	PostgresHost      string
-	PostgresPort      int
-	PostgresUser      string
-	PostgresPassword  string
-	PostgresDB        string
+	// Environment is "development" or "production". Production refuses
+	// to start with the built-in default credentials.
+	Environment string `yaml:"environment" env:"INGESTION_ENV"`
+
+	// SecretsDir holds one file per secret, named after its variable
+	// (POSTGRES_PASSWORD or postgres_password), as Docker and Kubernetes
+	// mount them. Secrets can also be read from the file named by the
+	// variable with a _FILE suffix, such as POSTGRES_PASSWORD_FILE.
+	SecretsDir string `yaml:"secrets_dir" env:"SECRETS_DIR"`
+
+	// LogLevel is "info" or "debug", which adds per-event lines.
+	LogLevel string `yaml:"log_level" env:"LOG_LEVEL" reload:"true"`
+
+	// SinkParseable and SinkValkey turn publishing of live events to each
+	// sink on or off.
+	SinkParseable bool `yaml:"sink_parseable" env:"SINK_PARSEABLE" reload:"true"`
+	SinkValkey    bool `yaml:"sink_valkey" env:"SINK_VALKEY" reload:"true"`
+
+	// ShutdownTimeout is how long, in seconds, in-flight scrapes and
+	// publishes get to finish after SIGTERM before the worker gives up and
+	// exits non-zero.
+	ShutdownTimeout int `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+
+	// AdminAddr is where the admin HTTP server (health, readiness,
+	// metrics, status) listens; empty disables it. AdminToken, when set,
+	// enables pprof under /debug/pprof/ for requests bearing it.
+	AdminAddr  string `yaml:"admin_addr" env:"ADMIN_ADDR"`
+	AdminToken string `yaml:"admin_token" env:"ADMIN_TOKEN" secret:"true"`
+
+	// LeaderElection lets several replicas run with only one of them,
+	// elected through a lease at LeaderKey in Valkey, scraping and
+	// publishing. LeaderTTL is the lease length in seconds: how long a dead
+	// leader can hold things up before a standby takes over.
+	LeaderElection bool   `yaml:"leader_election" env:"LEADER_ELECTION"`
+	LeaderKey      string `yaml:"leader_key" env:"LEADER_KEY"`
+	LeaderTTL      int    `yaml:"leader_ttl" env:"LEADER_TTL"`
+
+	ParseableURL      string `yaml:"parseable_url" env:"PARSEABLE_URL"`
+	ParseableUser     string `yaml:"parseable_user" env:"PARSEABLE_USER"`
+	ParseablePassword string `yaml:"parseable_password" env:"PARSEABLE_PASSWORD" secret:"true"`
+	ValkeyHost        string `yaml:"valkey_host" env:"VALKEY_HOST"`
+	ValkeyPort        int    `yaml:"valkey_port" env:"VALKEY_PORT"`
+	NTESBaseURL       string `yaml:"ntes_base_url" env:"NTES_BASE_URL"`
+	PollInterval      int    `yaml:"poll_interval" env:"INGESTION_POLL_INTERVAL" reload:"true"`
+	MockData          bool   `yaml:"mock_data" env:"MOCK_DATA"`
+	PostgresHost      string `yaml:"postgres_host" env:"POSTGRES_HOST"`
+	PostgresPort      int    `yaml:"postgres_port" env:"POSTGRES_PORT"`
+	PostgresUser      string `yaml:"postgres_user" env:"POSTGRES_USER"`
+	PostgresPassword  string `yaml:"postgres_password" env:"POSTGRES_PASSWORD" secret:"true"`
+	PostgresDB        string `yaml:"postgres_db" env:"POSTGRES_DB"`
+
+	// ScrapeBudget caps how many trains are scraped per poll cycle.
+	// ScrapeIdleCycles is how many cycles a train nobody is travelling on,
+	// watching or viewing waits between scrapes.
+	ScrapeBudget     int `yaml:"scrape_budget" env:"SCRAPE_BUDGET" reload:"true"`
+	ScrapeIdleCycles int `yaml:"scrape_idle_cycles" env:"SCRAPE_IDLE_CYCLES" reload:"true"`
+
+	// ScrapeBackoffMax caps, in seconds, how long a train that keeps failing
+	// or returning nothing is left between retries.
+	ScrapeBackoffMax int `yaml:"scrape_backoff_max" env:"SCRAPE_BACKOFF_MAX" reload:"true"`
+
+	// ScrapeRequestDelay is the pause, in seconds, between successive
+	// upstream requests for trains and station boards.
+	ScrapeRequestDelay int `yaml:"scrape_request_delay" env:"SCRAPE_REQUEST_DELAY" reload:"true"`
+
+	// ValidationMaxDelay is the largest delay, in minutes, a scraped event
+	// may report before it is quarantined.
+	ValidationMaxDelay int `yaml:"validation_max_delay" env:"VALIDATION_MAX_DELAY" reload:"true"`
+
+	// TimetableSyncInterval is how often, in hours, the scraper refreshes
+	// train_routes from the upstream schedule. Zero disables the job.
+	TimetableSyncInterval int  `yaml:"timetable_sync_interval_hours" env:"TIMETABLE_SYNC_INTERVAL_HOURS"`
+	TimetableSyncDryRun   bool `yaml:"timetable_sync_dry_run" env:"TIMETABLE_SYNC_DRY_RUN"`
+
+	// StationBoardStations lists the stations whose live arrivals and
+	// departures board is polled in scraper mode.
+	StationBoardStations []string `yaml:"station_board_stations" env:"STATION_BOARD_STATIONS" reload:"true"`
+	StationBoardHours    int      `yaml:"station_board_hours" env:"STATION_BOARD_HOURS" reload:"true"`
+	StationBoardInterval int      `yaml:"station_board_interval" env:"STATION_BOARD_INTERVAL" reload:"true"`
+
+	// RefreshQueueKey is the Valkey list the backend pushes on-demand
+	// "refresh train X now" requests onto. Empty disables the consumer.
+	RefreshQueueKey   string `yaml:"refresh_queue_key" env:"REFRESH_QUEUE_KEY"`
+	RefreshRatePerMin int    `yaml:"refresh_rate_per_min" env:"REFRESH_RATE_PER_MIN" reload:"true"`
+	RefreshMinAge     int    `yaml:"refresh_min_age" env:"REFRESH_MIN_AGE" reload:"true"`
+
+	// ArchiveBackend selects where raw upstream responses are kept:
+	// "none", "disk" (under ArchiveDir) or "postgres".
+	ArchiveBackend       string `yaml:"archive_backend" env:"ARCHIVE_BACKEND"`
+	ArchiveDir           string `yaml:"archive_dir" env:"ARCHIVE_DIR"`
+	ArchiveRetentionDays int    `yaml:"archive_retention_days" env:"ARCHIVE_RETENTION_DAYS" reload:"true"`
+
+	// MockSeed seeds the mock generator; zero picks a fresh seed each run.
+	// MockStartTime (RFC 3339) starts the simulation at a fixed moment and
+	// MockSpeed runs it faster than real time. Together they make mock
+	// streams reproducible.
+	MockSeed      int     `yaml:"mock_seed" env:"MOCK_SEED"`
+	MockStartTime string  `yaml:"mock_start_time" env:"MOCK_START_TIME"`
+	MockSpeed     float64 `yaml:"mock_speed" env:"MOCK_SPEED"`
+
+	// MockScenario is a YAML or JSON file scripting mock disruptions.
+	MockScenario string `yaml:"mock_scenario" env:"MOCK_SCENARIO"`
+
+	// MockLoadTrains switches mockgen into load-test mode with this many
+	// synthetic trains, publishing at up to MockLoadRate events per second
+	// (zero for unpaced).
+	MockLoadTrains int `yaml:"mock_load_trains" env:"MOCK_LOAD_TRAINS"`
+	MockLoadRate   int `yaml:"mock_load_rate" env:"MOCK_LOAD_RATE"`
 }
 
-func Load() *Config {
+// Built-in credentials, good only for local development.
+const (
+	defaultParseableUser     = "admin"
+	defaultParseablePassword = "admin"
+	defaultPostgresPassword  = "rail_secret_2024"
+)
+
+// Defaults returns the built-in configuration, before any config file,
+// flag or environment variable is applied.
+func Defaults() *Config {
 	return &Config{
-		ParseableURL:      getEnv("PARSEABLE_URL", "http://localhost:8000"),
-		ParseableUser:     getEnv("PARSEABLE_USER", "admin"),
-		ParseablePassword: getEnv("PARSEABLE_PASSWORD", "admin"),
-		ValkeyHost:        getEnv("VALKEY_HOST", "localhost"),
-		ValkeyPort:        getEnvInt("VALKEY_PORT", 6379),
-		NTESBaseURL:       getEnv("NTES_BASE_URL", "https://enquiry.indianrail.gov.in"),
-		PollInterval:      getEnvInt("INGESTION_POLL_INTERVAL", 60),
-		MockData:          getEnvBool("MOCK_DATA", true),
-		PostgresHost:      getEnv("POSTGRES_HOST", "localhost"),
-		PostgresPort:      getEnvInt("POSTGRES_PORT", 5432),
-		PostgresUser:      getEnv("POSTGRES_USER", "rail"),
-		PostgresPassword:  getEnv("POSTGRES_PASSWORD", "rail_secret_2024"),
-		PostgresDB:        getEnv("POSTGRES_DB", "rail"),
+		Environment: "development",
+		LogLevel:    "info",
+
+		SinkParseable: true,
+		SinkValkey:    true,
+
+		ShutdownTimeout: 25,
+
+		AdminAddr: ":9090",
+
+		LeaderElection: false,
+		LeaderKey:      "ingestion:leader",
+		LeaderTTL:      15,
+
+		ParseableURL:      "http://localhost:8000",
+		ParseableUser:     defaultParseableUser,
+		ParseablePassword: defaultParseablePassword,
+		ValkeyHost:        "localhost",
+		ValkeyPort:        6379,
+		NTESBaseURL:       "https://enquiry.indianrail.gov.in",
+		PollInterval:      60,
+		MockData:          true,
+		PostgresHost:      "localhost",
+		PostgresPort:      5432,
+		PostgresUser:      "rail",
+		PostgresPassword:  defaultPostgresPassword,
+		PostgresDB:        "rail",
+
+		ScrapeBudget:     25,
+		ScrapeIdleCycles: 5,
+		ScrapeBackoffMax: 1800,
+
+		ScrapeRequestDelay: 2,
+
+		ValidationMaxDelay: 1440,
+
+		TimetableSyncInterval: 0,
+		TimetableSyncDryRun:   false,
+
+		StationBoardStations: []string{"NDLS", "HWH", "BCT", "MAS", "SBC"},
+		StationBoardHours:    4,
+		StationBoardInterval: 300,
+
+		RefreshQueueKey:   "ingestion:refresh",
+		RefreshRatePerMin: 10,
+		RefreshMinAge:     30,
+
+		ArchiveBackend:       "none",
+		ArchiveDir:           "/var/lib/ingestion/archive",
+		ArchiveRetentionDays: 14,
+
+		MockSeed:      0,
+		MockStartTime: "",
+		MockSpeed:     1,
+		MockScenario:  "",
+
+		MockLoadTrains: 0,
+		MockLoadRate:   1000,
 	}
 }
 
-func getEnv(key, defaultVal string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
-	}
-	return defaultVal
+// Production reports whether the worker runs in production mode.
+func (c *Config) Production() bool {
+	return c.Environment == "production"
 }
 
-func getEnvInt(key string, defaultVal int) int {
-	if val := os.Getenv(key); val != "" {
-		if i, err := strconv.Atoi(val); err == nil {
-			return i
+// Secrets returns the secret values in use, for redaction from logs. The
+// built-in defaults are public and too common a word to redact.
+func (c *Config) Secrets() []string {
+	var out []string
+	for _, f := range settings(c) {
+		if v := f.value.String(); f.secret && v != "" && !isDefaultSecret(v) {
+			out = append(out, v)
 		}
 	}
-	return defaultVal
+	return out
 }
 
-func getEnvBool(key string, defaultVal bool) bool {
-	if val := os.Getenv(key); val != "" {
-		if b, err := strconv.ParseBool(val); err == nil {
-			return b
-		}
-	}
-	return defaultVal
+func isDefaultSecret(v string) bool {
+	return v == defaultParseablePassword || v == defaultPostgresPassword
+}
+
+// PostgresDSN returns the lib/pq connection string for the configured database.
+func (c *Config) PostgresDSN() string {
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		c.PostgresHost, c.PostgresPort, c.PostgresUser, c.PostgresPassword, c.PostgresDB,
+	)
 }
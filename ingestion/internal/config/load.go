@@ -0,0 +1,265 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from the built-in defaults, the YAML file
+// named by CONFIG_FILE and environment variables, each overriding the last,
+// and validates it. Any value that does not parse is an error rather than a
+// silent fallback to the default. Secrets can also come from <VAR>_FILE or
+// the secrets directory, which sits between the flags and the environment.
+func Load() (*Config, error) {
+	cfg, _, err := LoadArgs("", nil)
+	return cfg, err
+}
+
+// LoadArgs is Load for a command line. -config names the config file
+// (default $CONFIG_FILE) and every setting has a flag, which overrides the
+// file; environment variables still take precedence over both. It returns
+// the arguments left after the flags, even when the configuration is
+// invalid. With -h it returns flag.ErrHelp.
+func LoadArgs(name string, args []string) (*Config, []string, error) {
+	cfg := Defaults()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file")
+
+	type flagValue struct{ name, raw string }
+	var set []flagValue
+	for _, f := range settings(cfg) {
+		f := f
+		fs.Func(f.flag, fmt.Sprintf("%s (env %s)", f.key, f.env), func(raw string) error {
+			// Parse now so a bad flag fails with flag's own message; apply
+			// later, once the file has been read.
+			if err := parseInto(reflect.New(f.value.Type()).Elem(), raw); err != nil {
+				return err
+			}
+			set = append(set, flagValue{f.flag, raw})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *path != "" {
+		if err := loadFile(*path, cfg); err != nil {
+			return nil, fs.Args(), err
+		}
+	}
+
+	fields := make(map[string]setting)
+	for _, f := range settings(cfg) {
+		fields[f.flag] = f
+	}
+	for _, fv := range set {
+		if err := parseInto(fields[fv.name].value, fv.raw); err != nil {
+			return nil, fs.Args(), fmt.Errorf("-%s: %w", fv.name, err)
+		}
+	}
+
+	var errs []error
+	fromEnv := make(map[string]bool)
+	for _, f := range settings(cfg) {
+		raw, ok, err := lookupEnv(f)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// An empty variable is unset, except that an empty list is a list.
+		if !ok || (raw == "" && f.value.Kind() != reflect.Slice) {
+			continue
+		}
+		if err := parseInto(f.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q: %w", f.env, raw, err))
+		}
+		fromEnv[f.env] = true
+	}
+	if cfg.SecretsDir != "" {
+		if err := loadSecretsDir(cfg, fromEnv); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fs.Args(), errors.Join(errs...)
+	}
+
+	cfg.normalize()
+	if err := cfg.Validate(); err != nil {
+		return cfg, fs.Args(), err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// lookupEnv reads a setting's environment variable. A secret may instead
+// name a file holding it in <VAR>_FILE, but not both.
+func lookupEnv(f setting) (string, bool, error) {
+	raw, ok := os.LookupEnv(f.env)
+	if !f.secret {
+		return raw, ok, nil
+	}
+	path := os.Getenv(f.env + "_FILE")
+	if path == "" {
+		return raw, ok, nil
+	}
+	if raw != "" {
+		return "", false, fmt.Errorf("%s and %s_FILE are both set", f.env, f.env)
+	}
+	secret, err := readSecret(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", f.env, err)
+	}
+	return secret, true, nil
+}
+
+// loadSecretsDir reads secrets not already given in the environment from
+// files in cfg.SecretsDir. A missing file leaves the setting alone.
+func loadSecretsDir(cfg *Config, fromEnv map[string]bool) error {
+	for _, f := range settings(cfg) {
+		if !f.secret || fromEnv[f.env] {
+			continue
+		}
+		for _, name := range []string{f.env, strings.ToLower(f.env)} {
+			secret, err := readSecret(filepath.Join(cfg.SecretsDir, name))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("secrets dir: %w", err)
+			}
+			f.value.SetString(secret)
+			break
+		}
+	}
+	return nil
+}
+
+// readSecret reads a secret file, dropping the trailing newline editors
+// and echo leave behind.
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return secret, nil
+}
+
+// loadFile overlays the YAML file at path onto cfg. Unknown keys are
+// errors, so a misspelt setting is not silently ignored.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// setting is one configurable field of a Config.
+type setting struct {
+	key    string // yaml key
+	flag   string
+	env    string
+	secret bool
+	reload bool
+	value  reflect.Value
+}
+
+// settings lists cfg's fields in declaration order, addressable so they
+// can be set.
+func settings(cfg *Config) []setting {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	out := make([]setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := f.Tag.Get("yaml")
+		if key == "" {
+			continue
+		}
+		out = append(out, setting{
+			key:    key,
+			flag:   strings.ReplaceAll(key, "_", "-"),
+			env:    f.Tag.Get("env"),
+			secret: f.Tag.Get("secret") == "true",
+			reload: f.Tag.Get("reload") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return out
+}
+
+// label names a Config field in messages by its file key and variable.
+func label(field string) string {
+	f, ok := reflect.TypeOf(Config{}).FieldByName(field)
+	if !ok {
+		return field
+	}
+	return fmt.Sprintf("%s (%s)", f.Tag.Get("yaml"), f.Tag.Get("env"))
+}
+
+// parseInto sets v from its string form. Lists are comma-separated.
+func parseInto(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		i, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("not an integer")
+		}
+		v.SetInt(int64(i))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return fmt.Errorf("not a number")
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("not a boolean (use true or false)")
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// normalize tidies values that have a canonical form.
+func (c *Config) normalize() {
+	for i, code := range c.StationBoardStations {
+		c.StationBoardStations[i] = strings.ToUpper(strings.TrimSpace(code))
+	}
+	c.ArchiveBackend = strings.ToLower(strings.TrimSpace(c.ArchiveBackend))
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Reload applies a freshly loaded configuration to the running one. It
+// returns a copy of cur with fresh's reloadable settings, the settings that
+// changed, and the restart-only settings that differ, which keep their
+// running values.
+func Reload(cur, fresh *Config) (*Config, []string, []string) {
+	next := *cur
+	var changed, rejected []string
+	freshSettings := settings(fresh)
+	for i, f := range settings(&next) {
+		want := freshSettings[i].value
+		if reflect.DeepEqual(f.value.Interface(), want.Interface()) {
+			continue
+		}
+		name := fmt.Sprintf("%s (%s)", f.key, f.env)
+		if !f.reload {
+			rejected = append(rejected, name)
+			continue
+		}
+		f.value.Set(want)
+		changed = append(changed, name)
+	}
+	return &next, changed, rejected
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maskedSecret replaces secret values when the configuration is printed.
+const maskedSecret = "********"
+
+// Validate checks every setting and reports all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", label(field), fmt.Sprintf(format, args...)))
+	}
+	positive := func(field string, v int) {
+		if v <= 0 {
+			fail(field, "must be positive, got %d", v)
+		}
+	}
+	nonNegative := func(field string, v int) {
+		if v < 0 {
+			fail(field, "must not be negative, got %d", v)
+		}
+	}
+	port := func(field string, v int) {
+		if v < 1 || v > 65535 {
+			fail(field, "must be a port number (1-65535), got %d", v)
+		}
+	}
+	required := func(field, v string) {
+		if v == "" {
+			fail(field, "must be set")
+		}
+	}
+	httpURL := func(field, v string) {
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail(field, "must be an http(s) URL, got %q", v)
+		}
+	}
+
+	switch c.Environment {
+	case "development":
+	case "production":
+		if c.PostgresPassword == defaultPostgresPassword {
+			fail("PostgresPassword", "is the built-in default; set POSTGRES_PASSWORD, POSTGRES_PASSWORD_FILE or a secrets file")
+		}
+		if c.ParseablePassword == defaultParseablePassword {
+			fail("ParseablePassword", "is the built-in default; set PARSEABLE_PASSWORD, PARSEABLE_PASSWORD_FILE or a secrets file")
+		}
+		if c.ParseableUser == defaultParseableUser {
+			fail("ParseableUser", "is the built-in default %q", defaultParseableUser)
+		}
+	default:
+		fail("Environment", "must be development or production, got %q", c.Environment)
+	}
+
+	switch c.LogLevel {
+	case "debug", "info":
+	default:
+		fail("LogLevel", "must be debug or info, got %q", c.LogLevel)
+	}
+
+	if c.AdminAddr != "" {
+		if _, p, err := net.SplitHostPort(c.AdminAddr); err != nil {
+			fail("AdminAddr", "must be host:port or :port, got %q", c.AdminAddr)
+		} else if n, err := strconv.Atoi(p); err != nil || n < 0 || n > 65535 {
+			fail("AdminAddr", "has an invalid port %q", p)
+		}
+	}
+	if c.AdminToken != "" && len(c.AdminToken) < 16 {
+		fail("AdminToken", "must be at least 16 characters")
+	}
+
+	if c.LeaderElection {
+		required("LeaderKey", c.LeaderKey)
+		if c.LeaderTTL < 3 {
+			fail("LeaderTTL", "must be at least 3 seconds, got %d", c.LeaderTTL)
+		}
+	}
+
+	httpURL("ParseableURL", c.ParseableURL)
+	required("ParseablePassword", c.ParseablePassword)
+	required("ParseableUser", c.ParseableUser)
+	required("ValkeyHost", c.ValkeyHost)
+	port("ValkeyPort", c.ValkeyPort)
+	httpURL("NTESBaseURL", c.NTESBaseURL)
+	positive("PollInterval", c.PollInterval)
+	positive("ShutdownTimeout", c.ShutdownTimeout)
+	required("PostgresHost", c.PostgresHost)
+	port("PostgresPort", c.PostgresPort)
+	required("PostgresUser", c.PostgresUser)
+	required("PostgresPassword", c.PostgresPassword)
+	required("PostgresDB", c.PostgresDB)
+
+	positive("ScrapeBudget", c.ScrapeBudget)
+	nonNegative("ScrapeIdleCycles", c.ScrapeIdleCycles)
+	nonNegative("ScrapeBackoffMax", c.ScrapeBackoffMax)
+	nonNegative("ScrapeRequestDelay", c.ScrapeRequestDelay)
+	positive("ValidationMaxDelay", c.ValidationMaxDelay)
+	nonNegative("TimetableSyncInterval", c.TimetableSyncInterval)
+
+	for _, code := range c.StationBoardStations {
+		if code == "" || len(code) > 5 {
+			fail("StationBoardStations", "%q is not a station code", code)
+		}
+	}
+	if len(c.StationBoardStations) > 0 {
+		positive("StationBoardHours", c.StationBoardHours)
+		positive("StationBoardInterval", c.StationBoardInterval)
+	}
+
+	if c.RefreshQueueKey != "" {
+		// A zero rate would answer every request rate_limited.
+		positive("RefreshRatePerMin", c.RefreshRatePerMin)
+	} else {
+		nonNegative("RefreshRatePerMin", c.RefreshRatePerMin)
+	}
+	nonNegative("RefreshMinAge", c.RefreshMinAge)
+
+	switch c.ArchiveBackend {
+	case "none", "postgres":
+	case "disk":
+		required("ArchiveDir", c.ArchiveDir)
+	default:
+		fail("ArchiveBackend", "must be none, disk or postgres, got %q", c.ArchiveBackend)
+	}
+	nonNegative("ArchiveRetentionDays", c.ArchiveRetentionDays)
+
+	if c.MockStartTime != "" {
+		if _, err := time.Parse(time.RFC3339, c.MockStartTime); err != nil {
+			fail("MockStartTime", "must be an RFC 3339 time such as 2026-10-20T06:00:00+05:30, got %q", c.MockStartTime)
+		}
+	}
+	if c.MockSpeed <= 0 {
+		fail("MockSpeed", "must be positive, got %g", c.MockSpeed)
+	}
+	if c.MockScenario != "" && c.MockData {
+		if _, err := os.Stat(c.MockScenario); err != nil {
+			fail("MockScenario", "%v", err)
+		}
+	}
+	nonNegative("MockLoadTrains", c.MockLoadTrains)
+	nonNegative("MockLoadRate", c.MockLoadRate)
+
+	return errors.Join(errs...)
+}
+
+// Dump writes the effective configuration as YAML, in the config file's
+// format, with secrets masked.
+func (c *Config) Dump(w io.Writer) error {
+	masked := *c
+	for _, f := range settings(&masked) {
+		if f.secret && f.value.String() != "" {
+			f.value.Set(reflect.ValueOf(maskedSecret))
+		}
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&masked); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,430 @@
+// Package fakentes implements a local stand-in for the NTES mobile site.
+//
+// It serves the endpoints the scraper relies on (/mntes/,
+// /mntes/GetCSRFToken, the TrainRunning POST and the LiveStation POST) and
+// renders running-status and station-board HTML from a timetable and a
+// simple delay model. The Server is an
+// http.Handler so it can be mounted in httptest.NewServer for end-to-end
+// scraper tests, or run standalone through cmd/fakentes.
+package fakentes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"log"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/railtime"
+)
+
+const sessionCookie = "JSESSIONID"
+
+type Stop struct {
+	StationCode string
+	StationName string
+	Arrival     string // "HH:MM", empty at the source station
+	Departure   string // "HH:MM", empty at the destination station
+	DayNumber   int
+	Distance    int
+	Platform    string
+}
+
+type Train struct {
+	Number string
+	Name   string
+	Stops  []Stop
+}
+
+// DelayModel describes how late a run is at each stop. The delay at stop i
+// is Base + PerStop*i plus a per-run jitter in [0, Jitter], so a train picks
+// up delay as it travels and two different days get different numbers.
+type DelayModel struct {
+	BaseMinutes    int
+	PerStopMinutes int
+	JitterMinutes  int
+}
+
+type Options struct {
+	// SessionTTL expires sessions this long after /mntes/ handed them out.
+	// Zero means sessions never expire.
+	SessionTTL time.Duration
+	// RateLimit is the number of TrainRunning requests allowed per session
+	// per minute before the server answers 429. Zero disables throttling.
+	RateLimit int
+	// MalformedRate is the probability (0..1) that a TrainRunning response
+	// is deliberately garbled.
+	MalformedRate float64
+	Delay         DelayModel
+	Seed          int64
+	// Notices maps a train number to a service notice shown above its
+	// running status, e.g. "Train Cancelled" or "Diverted via Agra Cantt (AGC)".
+	Notices map[string]string
+	// Now returns the current time; defaults to time.Now.
+	Now func() time.Time
+}
+
+type session struct {
+	created   time.Time
+	csrfKey   string
+	csrfValue string
+	window    time.Time
+	requests  int
+}
+
+type Server struct {
+	opts   Options
+	trains map[string]Train
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	rng      *mrand.Rand
+	sessions map[string]*session
+	notices  map[string]string
+}
+
+func New(trains []Train, opts Options) *Server {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	s := &Server{
+		opts:     opts,
+		trains:   make(map[string]Train, len(trains)),
+		mux:      http.NewServeMux(),
+		rng:      mrand.New(mrand.NewSource(opts.Seed)),
+		sessions: make(map[string]*session),
+		notices:  make(map[string]string),
+	}
+	for _, t := range trains {
+		s.trains[t.Number] = t
+	}
+	for number, notice := range opts.Notices {
+		s.notices[number] = notice
+	}
+
+	s.mux.HandleFunc("/mntes/", s.handleBootstrap)
+	s.mux.HandleFunc("/mntes/GetCSRFToken", s.handleCSRF)
+	s.mux.HandleFunc("/mntes/tr", s.handleTrainRunning)
+	s.mux.HandleFunc("/mntes/q", s.handleLiveStation)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ExpireSessions drops every live session, as NTES does when it restarts.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]*session)
+}
+
+// SetNotice sets or, with an empty notice, clears the service notice for a
+// train.
+func (s *Server) SetNotice(trainNumber, notice string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if notice == "" {
+		delete(s.notices, trainNumber)
+		return
+	}
+	s.notices[trainNumber] = notice
+}
+
+// ---- Handlers ----
+
+func (s *Server) handleBootstrap(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/mntes/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	id := randomToken(16)
+	s.mu.Lock()
+	s.sessions[id] = &session{created: s.opts.Now()}
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/mntes"})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, "<html><head><title>NTES</title></head><body>National Train Enquiry System</body></html>")
+}
+
+func (s *Server) handleCSRF(w http.ResponseWriter, r *http.Request) {
+	sess := s.lookupSession(r)
+	if sess == nil {
+		http.Error(w, "no session", http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	sess.csrfKey = "csrf" + randomToken(4)
+	sess.csrfValue = randomToken(16)
+	key, value := sess.csrfKey, sess.csrfValue
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<input type='hidden' name='%s' value='%s'/>", key, value)
+}
+
+func (s *Server) handleTrainRunning(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Query().Get("opt") != "TrainRunning" {
+		http.NotFound(w, r)
+		return
+	}
+	malformed, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	train, ok := s.trains[r.PostForm.Get("trainNo")]
+	if !ok {
+		writeHTML(w, "<div class='error'>Train not found.</div>")
+		return
+	}
+
+	runDate, err := time.ParseInLocation("02-Jan-2006", r.PostForm.Get("jDate"), railtime.IST)
+	if err != nil {
+		http.Error(w, "invalid jDate", http.StatusBadRequest)
+		return
+	}
+
+	body := s.RenderRunningStatus(train, runDate, s.opts.Now())
+	if malformed {
+		body = garble(body)
+	}
+	writeHTML(w, body)
+}
+
+// authorize runs the session, CSRF and throttling checks shared by the
+// query endpoints. It writes the error response itself and reports whether
+// the request may proceed, and whether its response should be garbled.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) (malformed, ok bool) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return false, false
+	}
+
+	sess := s.lookupSession(r)
+	if sess == nil {
+		writeHTML(w, "<div class='error'>Session Expired. Please refresh the page.</div>")
+		return false, false
+	}
+
+	s.mu.Lock()
+	validToken := sess.csrfKey != "" && r.PostForm.Get(sess.csrfKey) == sess.csrfValue
+	throttled := s.throttleLocked(sess)
+	malformed = s.opts.MalformedRate > 0 && s.rng.Float64() < s.opts.MalformedRate
+	s.mu.Unlock()
+
+	if !validToken {
+		http.Error(w, "invalid csrf token", http.StatusForbidden)
+		return false, false
+	}
+	if throttled {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return false, false
+	}
+	return malformed, true
+}
+
+// lookupSession returns the caller's session, or nil if it is unknown or
+// has outlived SessionTTL.
+func (s *Server) lookupSession(r *http.Request) *session {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[c.Value]
+	if !ok {
+		return nil
+	}
+	if s.opts.SessionTTL > 0 && s.opts.Now().Sub(sess.created) > s.opts.SessionTTL {
+		delete(s.sessions, c.Value)
+		return nil
+	}
+	return sess
+}
+
+func (s *Server) throttleLocked(sess *session) bool {
+	if s.opts.RateLimit <= 0 {
+		return false
+	}
+	now := s.opts.Now()
+	if now.Sub(sess.window) >= time.Minute {
+		sess.window = now
+		sess.requests = 0
+	}
+	sess.requests++
+	return sess.requests > s.opts.RateLimit
+}
+
+// ---- Running Status ----
+
+// RenderRunningStatus builds the FindRunningInstance HTML for train as seen
+// at now: the run departing its source on runDate, preceded by any earlier
+// runs of a multi-day train that have not reached their destination yet.
+func (s *Server) RenderRunningStatus(train Train, runDate, now time.Time) string {
+	if len(train.Stops) == 0 {
+		return "<div>No running data available.</div>"
+	}
+
+	start := time.Date(runDate.Year(), runDate.Month(), runDate.Day(), 0, 0, 0, 0, railtime.IST)
+
+	var b strings.Builder
+	b.WriteString("<div class='runningStatus'>\n")
+
+	s.mu.Lock()
+	notice := s.notices[train.Number]
+	s.mu.Unlock()
+	if notice != "" {
+		fmt.Fprintf(&b, "<div class='notice'>%s</div>\n", notice)
+		lower := strings.ToLower(notice)
+		if strings.Contains(lower, "cancelled") && !strings.Contains(lower, "partially") {
+			fmt.Fprintf(&b, "<div>%s %s (Start Date: %s)</div>\n", train.Number, train.Name, start.Format("02-Jan-2006"))
+			b.WriteString("</div>")
+			return b.String()
+		}
+	}
+
+	lastDay := train.Stops[len(train.Stops)-1].DayNumber
+	for back := lastDay - 1; back >= 1; back-- {
+		earlier := start.AddDate(0, 0, -back)
+		if s.enRoute(train, earlier, now) {
+			s.renderInstance(&b, train, earlier, now)
+		}
+	}
+	s.renderInstance(&b, train, start, now)
+
+	b.WriteString("</div>")
+	return b.String()
+}
+
+// renderInstance writes the header and events of the run starting at start.
+func (s *Server) renderInstance(b *strings.Builder, train Train, start, now time.Time) {
+	fmt.Fprintf(b, "<div>%s %s (Start Date: %s)</div>\n", train.Number, train.Name, start.Format("02-Jan-2006"))
+
+	jitter := s.runJitter(train.Number, start)
+	started := false
+	for i, stop := range train.Stops {
+		delay := s.stopDelay(i, jitter)
+
+		if t, ok := stopTime(start, stop.Arrival, stop.DayNumber); ok {
+			actual := t.Add(time.Duration(delay) * time.Minute)
+			if !actual.After(now) {
+				b.WriteString(eventLine("Arrived at", stop, actual, delay))
+				started = true
+			}
+		}
+		if t, ok := stopTime(start, stop.Departure, stop.DayNumber); ok {
+			actual := t.Add(time.Duration(delay) * time.Minute)
+			if !actual.After(now) {
+				b.WriteString(eventLine("Departed from", stop, actual, delay))
+				started = true
+			}
+		}
+	}
+
+	if !started {
+		b.WriteString("<div>Yet to start from its source station.</div>\n")
+	}
+}
+
+// enRoute reports whether the run starting at start has left its source but
+// not yet arrived at its destination by now.
+func (s *Server) enRoute(train Train, start, now time.Time) bool {
+	jitter := s.runJitter(train.Number, start)
+
+	first := train.Stops[0]
+	dep, ok := stopTime(start, first.Departure, first.DayNumber)
+	if !ok || dep.Add(time.Duration(s.stopDelay(0, jitter))*time.Minute).After(now) {
+		return false
+	}
+
+	i := len(train.Stops) - 1
+	last := train.Stops[i]
+	arr, ok := stopTime(start, last.Arrival, last.DayNumber)
+	if !ok {
+		return false
+	}
+	return arr.Add(time.Duration(s.stopDelay(i, jitter)) * time.Minute).After(now)
+}
+
+// stopDelay is the delay model's delay at the i-th stop of a run.
+func (s *Server) stopDelay(i, jitter int) int {
+	return s.opts.Delay.BaseMinutes + s.opts.Delay.PerStopMinutes*i + jitter
+}
+
+func (s *Server) runJitter(trainNumber string, start time.Time) int {
+	if s.opts.Delay.JitterMinutes <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:%s:%s", s.opts.Seed, trainNumber, start.Format("2006-01-02"))
+	return int(h.Sum64() % uint64(s.opts.Delay.JitterMinutes+1))
+}
+
+func eventLine(verb string, stop Stop, actual time.Time, delay int) string {
+	line := fmt.Sprintf("<div>%s %s (%s) at %s %s",
+		verb, stop.StationName, stop.StationCode,
+		actual.Format("15:04"), actual.Format("02-Jan"))
+	if delay > 0 {
+		line += fmt.Sprintf(" Delay: %02d:%02d", delay/60, delay%60)
+	} else {
+		line += " On Time"
+	}
+	if stop.Platform != "" {
+		line += " PF " + stop.Platform
+	}
+	return line + "</div>\n"
+}
+
+func stopTime(start time.Time, hhmm string, dayNumber int) (time.Time, bool) {
+	if hhmm == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("15:04", hhmm[:min(5, len(hhmm))])
+	if err != nil {
+		return time.Time{}, false
+	}
+	if dayNumber < 1 {
+		dayNumber = 1
+	}
+	return start.AddDate(0, 0, dayNumber-1).Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), true
+}
+
+// garble corrupts a response the way flaky upstream pages do: truncated
+// mid-line with the time fields mangled.
+func garble(body string) string {
+	body = strings.NewReplacer(":", "?", " at ", " @ ").Replace(body)
+	return body[:len(body)/2]
+}
+
+func writeHTML(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, body)
+}
+
+func randomToken(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("fakentes: random token: %v", err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
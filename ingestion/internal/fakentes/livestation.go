@@ -0,0 +1,120 @@
+package fakentes
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/railtime"
+)
+
+type boardRow struct {
+	expected time.Time
+	line     string
+}
+
+func (s *Server) handleLiveStation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Query().Get("opt") != "LiveStation" {
+		http.NotFound(w, r)
+		return
+	}
+
+	malformed, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	code := strings.ToUpper(r.PostForm.Get("stnCode"))
+	hours, err := strconv.Atoi(r.PostForm.Get("hrs"))
+	if err != nil || hours <= 0 {
+		hours = 2
+	}
+
+	body := s.RenderLiveStation(code, time.Duration(hours)*time.Hour, s.opts.Now())
+	if malformed {
+		body = garble(body)
+	}
+	writeHTML(w, body)
+}
+
+// RenderLiveStation builds the LiveStation HTML listing every train expected
+// to arrive at or depart from code within window of now.
+func (s *Server) RenderLiveStation(code string, window time.Duration, now time.Time) string {
+	local := now.In(railtime.IST)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, railtime.IST)
+	until := now.Add(window)
+
+	var rows []boardRow
+	for _, train := range s.trains {
+		if len(train.Stops) == 0 {
+			continue
+		}
+		maxDay := train.Stops[len(train.Stops)-1].DayNumber
+		// A run that started a few days ago may still be on its way.
+		for back := maxDay - 1; back >= 0; back-- {
+			start := today.AddDate(0, 0, -back)
+			jitter := s.runJitter(train.Number, start)
+
+			for i, stop := range train.Stops {
+				if stop.StationCode != code {
+					continue
+				}
+				delay := s.stopDelay(i, jitter)
+				schArr, hasArr := stopTime(start, stop.Arrival, stop.DayNumber)
+				schDep, hasDep := stopTime(start, stop.Departure, stop.DayNumber)
+				expArr := schArr.Add(time.Duration(delay) * time.Minute)
+				expDep := schDep.Add(time.Duration(delay) * time.Minute)
+
+				expected := expArr
+				if !hasArr {
+					expected = expDep
+				}
+				last := expDep
+				if !hasDep {
+					last = expArr
+				}
+				if last.Before(now) || expected.After(until) {
+					continue
+				}
+
+				line := fmt.Sprintf("<div>%s %s | Sch Arr: %s | Exp Arr: %s | Sch Dep: %s | Exp Dep: %s | Delay: %02d:%02d",
+					train.Number, train.Name,
+					clock(schArr, hasArr), clock(expArr, hasArr),
+					clock(schDep, hasDep), clock(expDep, hasDep),
+					delay/60, delay%60)
+				if stop.Platform != "" {
+					line += " | PF " + stop.Platform
+				}
+				rows = append(rows, boardRow{expected: expected, line: line + "</div>\n"})
+			}
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].expected.Equal(rows[j].expected) {
+			return rows[i].expected.Before(rows[j].expected)
+		}
+		return rows[i].line < rows[j].line
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<div class='liveStation'>\n<div>Live Station %s, next %d hours</div>\n", code, int(window.Hours()))
+	for _, row := range rows {
+		b.WriteString(row.line)
+	}
+	if len(rows) == 0 {
+		b.WriteString("<div>No trains scheduled in the selected window.</div>\n")
+	}
+	b.WriteString("</div>")
+	return b.String()
+}
+
+func clock(t time.Time, ok bool) string {
+	if !ok {
+		return "--"
+	}
+	return t.Format("15:04")
+}
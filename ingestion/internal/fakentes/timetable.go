@@ -0,0 +1,44 @@
+package fakentes
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// LoadTrains reads every train with a route from the trains and
+// train_routes tables.
+func LoadTrains(db *sql.DB) ([]Train, error) {
+	rows, err := db.Query(`
+		SELECT t.number, t.name, tr.station_code, COALESCE(s.name, tr.station_code),
+			   COALESCE(to_char(tr.arrival_time, 'HH24:MI'), ''),
+			   COALESCE(to_char(tr.departure_time, 'HH24:MI'), ''),
+			   tr.day_number, tr.distance_from_source, COALESCE(tr.platform, '')
+		FROM trains t
+		JOIN train_routes tr ON tr.train_number = t.number
+		LEFT JOIN stations s ON s.code = tr.station_code
+		ORDER BY t.number, tr.stop_number ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query routes: %w", err)
+	}
+	defer rows.Close()
+
+	var trains []Train
+	for rows.Next() {
+		var number, name string
+		var stop Stop
+		if err := rows.Scan(
+			&number, &name, &stop.StationCode, &stop.StationName,
+			&stop.Arrival, &stop.Departure,
+			&stop.DayNumber, &stop.Distance, &stop.Platform,
+		); err != nil {
+			return nil, fmt.Errorf("scan route stop: %w", err)
+		}
+		if len(trains) == 0 || trains[len(trains)-1].Number != number {
+			trains = append(trains, Train{Number: number, Name: name})
+		}
+		last := &trains[len(trains)-1]
+		last.Stops = append(last.Stops, stop)
+	}
+	return trains, rows.Err()
+}
@@ -0,0 +1,280 @@
+// Package leader elects one ingestion replica to scrape and publish, using
+// a lease in Valkey. The lease is a key set with NX and a TTL and renewed
+// by its holder; a standby takes it over once it expires. Every new lease
+// gets a fencing token from a counter, so a deposed leader that has not
+// noticed yet can be told apart from the current one.
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/rail-app/ingestion/internal/metrics"
+)
+
+// Roles, as reported in logs and status.
+const (
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+)
+
+var (
+	isLeader = metrics.NewGauge("ingestion_leader",
+		"Whether this replica holds the leader lease (1) or is on standby (0).")
+	transitions = metrics.NewCounter("ingestion_leader_transitions_total",
+		"Times this replica gained or lost the leader lease.")
+)
+
+// acquire sets the lease if nobody holds it and, if so, takes the next
+// fencing token and stores it in the lease alongside the holder's id.
+var acquire = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	local token = redis.call('INCR', KEYS[2])
+	redis.call('SET', KEYS[1], ARGV[1] .. ' ' .. token, 'PX', ARGV[2])
+	return token
+end
+return 0
+`)
+
+// renew extends the lease if it is still ours.
+var renew = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// release deletes the lease if it is still ours.
+var release = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Elector campaigns for the lease and holds it while this replica leads.
+type Elector struct {
+	rdb *redis.Client
+	key string
+	id  string
+	ttl time.Duration
+
+	mu      sync.Mutex
+	token   int64     // fencing token of the current term; 0 on standby
+	expires time.Time // when our lease lapses if not renewed
+	holder  string    // last known leader, ours or another replica's
+}
+
+// New returns an elector for the lease at key in the Valkey at addr. The
+// replica is identified by its hostname and process id.
+func New(addr, key string, ttl time.Duration) *Elector {
+	host, _ := os.Hostname()
+	return &Elector{
+		rdb: redis.NewClient(&redis.Options{Addr: addr}),
+		key: key,
+		id:  fmt.Sprintf("%s-%d", host, os.Getpid()),
+		ttl: ttl,
+	}
+}
+
+// FenceKey is the Valkey key holding the newest fencing token. A write
+// guarded by the fence goes through only while it still holds the token
+// of the writer's term.
+func (e *Elector) FenceKey() string {
+	return e.key + ":fence"
+}
+
+// Fence returns the fencing key and this replica's token. ok is false on
+// standby, and once the lease may have lapsed without a renewal.
+func (e *Elector) Fence() (key string, token int64, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.token == 0 || !time.Now().Before(e.expires) {
+		return "", 0, false
+	}
+	return e.FenceKey(), e.token, true
+}
+
+// Status is the elector's view, for the status endpoint.
+type Status struct {
+	Role   string `json:"role"`
+	ID     string `json:"id"`
+	Leader string `json:"leader,omitempty"`
+	Token  int64  `json:"fencing_token,omitempty"`
+}
+
+// Status returns this replica's role and the leader it knows of.
+func (e *Elector) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := Status{Role: RoleStandby, ID: e.id, Leader: e.holder}
+	if e.token != 0 {
+		st.Role = RoleLeader
+		st.Token = e.token
+	}
+	return st
+}
+
+// Run campaigns for the lease until ctx is cancelled. Each time it wins, it
+// calls lead with a context that is cancelled when the lease is lost or ctx
+// is, and waits for lead to return before campaigning again.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	log.Printf("Leader election: %s campaigning for %s", e.id, e.key)
+	retry := e.ttl / 3
+	for {
+		token, err := e.tryAcquire(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Leader election: %v", err)
+		}
+		if token > 0 {
+			e.term(ctx, token, lead)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// tryAcquire takes the lease if it is free. It returns the new fencing
+// token, or 0 when another replica holds the lease.
+func (e *Elector) tryAcquire(ctx context.Context) (int64, error) {
+	start := time.Now()
+	token, err := acquire.Run(ctx, e.rdb, []string{e.key, e.FenceKey()}, e.id, e.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("acquire: %w", err)
+	}
+	if token == 0 {
+		e.noteHolder(ctx)
+		return 0, nil
+	}
+
+	e.mu.Lock()
+	e.token = token
+	e.expires = start.Add(e.ttl)
+	e.holder = e.id
+	e.mu.Unlock()
+	isLeader.Set(1)
+	transitions.Inc()
+	log.Printf("Leader election: %s is now leader (fencing token %d)", e.id, token)
+	return token, nil
+}
+
+// noteHolder records who holds the lease, logging when that changes.
+func (e *Elector) noteHolder(ctx context.Context) {
+	val, err := e.rdb.Get(ctx, e.key).Result()
+	if err != nil {
+		return
+	}
+	holder, _, _ := strings.Cut(val, " ")
+	e.mu.Lock()
+	changed := holder != e.holder
+	e.holder = holder
+	e.mu.Unlock()
+	if changed {
+		log.Printf("Leader election: %s is standby, leader is %s", e.id, holder)
+	}
+}
+
+// term runs lead for as long as the lease is held. The lease is renewed
+// until lead returns, so a leader draining on shutdown keeps its fence, and
+// then released so a standby need not wait for it to expire.
+func (e *Elector) term(ctx context.Context, token int64, lead func(ctx context.Context)) {
+	termCtx, depose := context.WithCancel(ctx)
+	defer depose()
+	keepCtx, stopKeeping := context.WithCancel(context.Background())
+
+	value := e.id + " " + strconv.FormatInt(token, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.keepAlive(keepCtx, depose, value)
+	}()
+
+	lead(termCtx)
+	stopKeeping()
+	<-done
+
+	e.mu.Lock()
+	held := e.token != 0
+	e.token = 0
+	e.mu.Unlock()
+	isLeader.Set(0)
+	transitions.Inc()
+	if !held {
+		return
+	}
+
+	releaseCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := release.Run(releaseCtx, e.rdb, []string{e.key}, value).Err(); err != nil {
+		log.Printf("Leader election: releasing lease: %v", err)
+		return
+	}
+	log.Printf("Leader election: %s released leadership", e.id)
+}
+
+// keepAlive renews the lease every third of its TTL and calls depose as
+// soon as the lease is gone or cannot be renewed before it lapses.
+func (e *Elector) keepAlive(ctx context.Context, depose func(), value string) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		renewCtx, cancel := context.WithTimeout(ctx, e.ttl/3)
+		ok, err := renew.Run(renewCtx, e.rdb, []string{e.key}, value, e.ttl.Milliseconds()).Int64()
+		cancel()
+		switch {
+		case err == nil && ok == 1:
+			e.mu.Lock()
+			e.expires = start.Add(e.ttl)
+			e.mu.Unlock()
+			continue
+		case err == nil:
+			e.stepDown(depose, "lost the lease")
+			return
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		e.mu.Lock()
+		lapsed := !time.Now().Add(e.ttl / 3).Before(e.expires)
+		e.mu.Unlock()
+		if lapsed {
+			e.stepDown(depose, fmt.Sprintf("cannot renew the lease (%v)", err))
+			return
+		}
+		log.Printf("Leader election: renewing lease: %v", err)
+	}
+}
+
+// stepDown gives up the fencing token at once, so nothing more is published
+// while the term's work winds down, and ends the term.
+func (e *Elector) stepDown(depose func(), reason string) {
+	e.mu.Lock()
+	e.token = 0
+	e.mu.Unlock()
+	log.Printf("Leader election: %s %s, stepping down", e.id, reason)
+	depose()
+}
+
+// Close closes the elector's Valkey connection. Call it after Run returns.
+func (e *Elector) Close() error {
+	return e.rdb.Close()
+}
@@ -0,0 +1,22 @@
+// Package logging holds the worker's log level.
+package logging
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+var debug atomic.Bool
+
+// SetLevel sets the log level: "debug" turns on Debugf output, anything
+// else turns it off.
+func SetLevel(level string) {
+	debug.Store(level == "debug")
+}
+
+// Debugf logs like log.Printf when the level is debug.
+func Debugf(format string, args ...interface{}) {
+	if debug.Load() {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DurationBuckets suit request and publish latencies, in seconds.
+var DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	buckets []float64 // upper bounds, ascending
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// ObserveSince records the seconds elapsed since start.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+func (h *Histogram) write(w io.Writer, name, labels string) {
+	h.mu.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	var cum uint64
+	for i, upper := range h.buckets {
+		cum += counts[i]
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(labels, "le", formatFloat(upper)), cum)
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(labels, "le", "+Inf"), count)
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, count)
+}
+
+// withLabel adds name="value" to rendered labels.
+func withLabel(labels, name, value string) string {
+	pair := fmt.Sprintf(`%s="%s"`, name, value)
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return strings.TrimSuffix(labels, "}") + "," + pair + "}"
+}
+
+// NewHistogram registers a histogram without labels.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return NewHistogramVec(name, help, buckets).With()
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct{ f *family }
+
+// NewHistogramVec registers a histogram with the given bucket upper bounds,
+// which must be ascending, and label names.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: " + name + " buckets are not ascending")
+	}
+	return &HistogramVec{register(name, help, "histogram", labels, func() sample {
+		return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets)+1)}
+	})}
+}
+
+// With returns the histogram for the label values, in label order.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.f.with(values).(*Histogram)
+}
+
+// Age is a gauge reporting the seconds since the latest time recorded in
+// it, computed when scraped.
+type Age struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// Observe records t if it is later than the time already held.
+func (a *Age) Observe(t time.Time) {
+	a.mu.Lock()
+	if t.After(a.t) {
+		a.t = t
+	}
+	a.mu.Unlock()
+}
+
+func (a *Age) write(w io.Writer, name, labels string) {
+	a.mu.Lock()
+	t := a.t
+	a.mu.Unlock()
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(time.Since(t).Seconds()))
+}
+
+// AgeVec is an age gauge partitioned by labels.
+type AgeVec struct{ f *family }
+
+// NewAgeVec registers an age gauge with the given label names.
+func NewAgeVec(name, help string, labels ...string) *AgeVec {
+	return &AgeVec{register(name, help, "gauge", labels, func() sample { return &Age{} })}
+}
+
+// With returns the age gauge for the label values, in label order.
+func (v *AgeVec) With(values ...string) *Age {
+	return v.f.with(values).(*Age)
+}
+
+// Delete drops the age gauge for the label values, in label order, so a
+// series that no longer applies stops being exported.
+func (v *AgeVec) Delete(values ...string) {
+	v.f.delete(values)
+}
@@ -0,0 +1,275 @@
+// Package metrics is a small registry of counters, gauges and histograms
+// served in the Prometheus text exposition format. Metrics are registered
+// once, at package init in the code that updates them, and live for the
+// process.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sample is one metric value, written as one or more exposition lines.
+type sample interface {
+	write(w io.Writer, name, labels string)
+}
+
+// family is a named metric and its children, one per set of label values.
+type family struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu       sync.Mutex
+	children map[string]*child
+	newChild func() sample
+}
+
+type child struct {
+	labels string // rendered {k="v",...}
+	sample sample
+}
+
+var registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+func register(name, help, typ string, labels []string, newChild func() sample) *family {
+	f := &family{
+		name:     name,
+		help:     help,
+		typ:      typ,
+		labels:   labels,
+		children: make(map[string]*child),
+		newChild: newChild,
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	for _, g := range registry.families {
+		if g.name == name {
+			panic("metrics: " + name + " registered twice")
+		}
+	}
+	registry.families = append(registry.families, f)
+	return f
+}
+
+// with returns the child for the label values, creating it on first use.
+func (f *family) with(values []string) sample {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.children[key]
+	if !ok {
+		c = &child{labels: renderLabels(f.labels, values), sample: f.newChild()}
+		f.children[key] = c
+	}
+	return c.sample
+}
+
+// delete removes the child for the label values, if there is one.
+func (f *family) delete(values []string) {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	f.mu.Lock()
+	delete(f.children, strings.Join(values, "\xff"))
+	f.mu.Unlock()
+}
+
+func (f *family) write(w io.Writer) {
+	f.mu.Lock()
+	children := make([]*child, 0, len(f.children))
+	for _, c := range f.children {
+		children = append(children, c)
+	}
+	f.mu.Unlock()
+	if len(children) == 0 {
+		return
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].labels < children[j].labels })
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+	for _, c := range children {
+		c.sample.write(w, f.name, c.labels)
+	}
+}
+
+func renderLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// value is a float guarded by a mutex, shared by counters and gauges.
+type value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (v *value) add(d float64) {
+	v.mu.Lock()
+	v.v += d
+	v.mu.Unlock()
+}
+
+func (v *value) set(f float64) {
+	v.mu.Lock()
+	v.v = f
+	v.mu.Unlock()
+}
+
+func (v *value) get() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.v
+}
+
+func (v *value) write(w io.Writer, name, labels string) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(v.get()))
+}
+
+// Counter only goes up.
+type Counter struct{ value }
+
+// Inc adds one.
+func (c *Counter) Inc() { c.add(1) }
+
+// Add adds d, which must not be negative.
+func (c *Counter) Add(d float64) {
+	if d < 0 {
+		panic("metrics: counter decreased")
+	}
+	c.add(d)
+}
+
+// NewCounter registers a counter without labels.
+func NewCounter(name, help string) *Counter {
+	return NewCounterVec(name, help).With()
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct{ f *family }
+
+// NewCounterVec registers a counter with the given label names.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{register(name, help, "counter", labels, func() sample { return &Counter{} })}
+}
+
+// With returns the counter for the label values, in label order.
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.f.with(values).(*Counter)
+}
+
+// Gauge goes up and down.
+type Gauge struct{ value }
+
+// Set sets the gauge to f.
+func (g *Gauge) Set(f float64) { g.set(f) }
+
+// Add adds d, which may be negative.
+func (g *Gauge) Add(d float64) { g.add(d) }
+
+// SetToCurrentTime sets the gauge to the current Unix time in seconds.
+func (g *Gauge) SetToCurrentTime() { g.Set(float64(time.Now().UnixNano()) / 1e9) }
+
+// NewGauge registers a gauge without labels.
+func NewGauge(name, help string) *Gauge {
+	return NewGaugeVec(name, help).With()
+}
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct{ f *family }
+
+// NewGaugeVec registers a gauge with the given label names.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{register(name, help, "gauge", labels, func() sample { return &Gauge{} })}
+}
+
+// With returns the gauge for the label values, in label order.
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.f.with(values).(*Gauge)
+}
+
+// gaugeFunc reads its value when scraped.
+type gaugeFunc func() float64
+
+func (fn gaugeFunc) write(w io.Writer, name, labels string) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(fn()))
+}
+
+// NewGaugeFunc registers a gauge whose value fn computes at scrape time.
+func NewGaugeFunc(name, help string, fn func() float64) {
+	register(name, help, "gauge", nil, func() sample { return gaugeFunc(fn) }).with(nil)
+}
+
+// WriteTo writes every registered metric in the text exposition format.
+func WriteTo(w io.Writer) {
+	registry.mu.Lock()
+	families := append([]*family(nil), registry.families...)
+	registry.mu.Unlock()
+	for _, f := range families {
+		f.write(w)
+	}
+}
+
+// Handler serves the registered metrics.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteTo(w)
+	})
+}
+
+func init() {
+	start := float64(time.Now().Unix())
+	NewGaugeFunc("process_start_time_seconds", "Start time of the process since the Unix epoch in seconds.",
+		func() float64 { return start })
+	NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.",
+		func() float64 { return float64(runtime.NumGoroutine()) })
+	NewGaugeFunc("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.",
+		func() float64 {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			return float64(m.HeapAlloc)
+		})
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exposition returns the lines WriteTo writes for the named family.
+func exposition(t *testing.T, name string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	WriteTo(&buf)
+
+	var out []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 3 && fields[0] == "#" && fields[2] == name:
+		case strings.HasPrefix(line, name+"{"), strings.HasPrefix(line, name+" "), strings.HasPrefix(line, name+"_"):
+		default:
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestCounterLabelEscaping(t *testing.T) {
+	c := NewCounterVec("test_escaped_total", "Counts things.", "path", "note")
+	c.With(`C:\dir`, "say \"hi\"\nbye").Inc()
+	c.With("plain", "").Add(2.5)
+
+	want := `# HELP test_escaped_total Counts things.
+# TYPE test_escaped_total counter
+test_escaped_total{path="C:\\dir",note="say \"hi\"\nbye"} 1
+test_escaped_total{path="plain",note=""} 2.5`
+	if got := exposition(t, "test_escaped_total"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnusedFamilyIsOmitted(t *testing.T) {
+	NewCounterVec("test_unused_total", "Never incremented.", "kind")
+	if got := exposition(t, "test_unused_total"); got != "" {
+		t.Errorf("got:\n%s\nwant nothing for a family without children", got)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	h := NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 0.5, 1}, "op")
+	hist := h.With("read")
+	for _, v := range []float64{0.05, 0.1, 0.3, 1, 2} {
+		hist.Observe(v)
+	}
+
+	// A value equal to a bound falls in that bucket (le is inclusive), and
+	// every bucket counts the ones below it.
+	want := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{op="read",le="0.1"} 2
+test_latency_seconds_bucket{op="read",le="0.5"} 3
+test_latency_seconds_bucket{op="read",le="1"} 4
+test_latency_seconds_bucket{op="read",le="+Inf"} 5
+test_latency_seconds_sum{op="read"} 3.45
+test_latency_seconds_count{op="read"} 5`
+	if got := exposition(t, "test_latency_seconds"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramWithoutLabels(t *testing.T) {
+	h := NewHistogram("test_size_bytes", "Sizes.", []float64{10})
+	h.Observe(10)
+
+	want := `# HELP test_size_bytes Sizes.
+# TYPE test_size_bytes histogram
+test_size_bytes_bucket{le="10"} 1
+test_size_bytes_bucket{le="+Inf"} 1
+test_size_bytes_sum 10
+test_size_bytes_count 1`
+	if got := exposition(t, "test_size_bytes"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGauges(t *testing.T) {
+	g := NewGauge("test_queue_depth", "Queue depth.")
+	g.Set(7)
+	g.Add(-2)
+
+	n := 3.0
+	NewGaugeFunc("test_computed", "Computed when scraped.", func() float64 { return n })
+	n = 4
+
+	for name, want := range map[string]string{
+		"test_queue_depth": "# HELP test_queue_depth Queue depth.\n# TYPE test_queue_depth gauge\ntest_queue_depth 5",
+		"test_computed":    "# HELP test_computed Computed when scraped.\n# TYPE test_computed gauge\ntest_computed 4",
+	} {
+		if got := exposition(t, name); got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	NewCounter("test_twice_total", "Registered twice.")
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	NewCounter("test_twice_total", "Registered twice.")
+}
+
+func TestAgeVecDelete(t *testing.T) {
+	v := NewAgeVec("test_age_seconds", "Age.", "train")
+	v.With("12301").Observe(time.Now())
+	v.With("12302").Observe(time.Now())
+	v.Delete("12301")
+	v.Delete("99999")
+
+	got := exposition(t, "test_age_seconds")
+	if strings.Contains(got, `train="12301"`) || !strings.Contains(got, `train="12302"`) {
+		t.Errorf("got:\n%s\nwant only the series for 12302", got)
+	}
+}
@@ -0,0 +1,108 @@
+package mockgen
+
+import (
+	"time"
+)
+
+// blockHeadway is the gap kept behind a train clearing a block section
+// before the next train is let into it.
+const blockHeadway = 2 * time.Minute
+
+// section is a block section between consecutive stops, in the direction
+// of travel. Trains that share a pair of consecutive stops share the
+// section, whatever route they are on.
+type section struct {
+	From, To string
+}
+
+// occupancy is one run's passage through a section.
+type occupancy struct {
+	run   *trainRun
+	enter time.Time
+	exit  time.Time
+}
+
+// blockGraph enforces absolute block working: one train at a time in each
+// direction of a section, so a following train waits at the last stop until
+// the one ahead has cleared. Lines are taken to be double, so opposing
+// trains pass freely.
+type blockGraph struct {
+	sections map[section][]*occupancy
+}
+
+// newBlockGraph builds the section graph from the routes' consecutive
+// stops.
+func newBlockGraph(routes []trainRoute) *blockGraph {
+	g := &blockGraph{sections: make(map[section][]*occupancy)}
+	for i := range routes {
+		stops := routes[i].Stops
+		for j := 0; j+1 < len(stops); j++ {
+			s := section{stops[j].StationCode, stops[j+1].StationCode}
+			if _, ok := g.sections[s]; !ok {
+				g.sections[s] = nil
+			}
+		}
+	}
+	return g
+}
+
+// heldUntil returns when r may enter the section from its current stop if
+// it is occupied at t, and the run holding it.
+func (g *blockGraph) heldUntil(r *trainRun, t time.Time) (time.Time, *trainRun, bool) {
+	if g == nil {
+		return time.Time{}, nil, false
+	}
+	var until time.Time
+	var ahead *trainRun
+	for _, o := range g.sections[r.section()] {
+		if o.run == r {
+			continue
+		}
+		free := o.exit.Add(blockHeadway)
+		if !o.enter.After(t) && free.After(t) && free.After(until) {
+			until, ahead = free, o.run
+		}
+	}
+	return until, ahead, ahead != nil
+}
+
+// enter records r running through its current section from enter to exit.
+func (g *blockGraph) enter(r *trainRun, enter, exit time.Time) {
+	if g == nil {
+		return
+	}
+	s := r.section()
+	g.sections[s] = append(g.sections[s], &occupancy{run: r, enter: enter, exit: exit})
+}
+
+// prune forgets passages that cleared their section before cutoff.
+func (g *blockGraph) prune(cutoff time.Time) {
+	if g == nil {
+		return
+	}
+	for s, occ := range g.sections {
+		kept := occ[:0]
+		for _, o := range occ {
+			if o.exit.Add(blockHeadway).After(cutoff) {
+				kept = append(kept, o)
+			}
+		}
+		g.sections[s] = kept
+	}
+}
+
+// section is the block section the run is about to enter or is running
+// through.
+func (r *trainRun) section() section {
+	return section{r.route.Stops[r.stop].StationCode, r.route.Stops[r.stop+1].StationCode}
+}
+
+// holdCause is the delay cause given when r waits for ahead to clear a
+// section: a train of a faster class being let through first counts as
+// waiting for a crossing, anything else as congestion.
+func holdCause(r, ahead *trainRun) string {
+	if ahead.profile.MaxSpeed > r.profile.MaxSpeed {
+		return "Waiting for Crossing"
+	}
+	return "Congestion"
+}
@@ -0,0 +1,51 @@
+package mockgen
+
+import (
+	"fmt"
+	"time"
+)
+
+// Clock is the generator's source of simulated time. Advance is called once
+// per tick with the simulated time the tick covers.
+type Clock interface {
+	Now() time.Time
+	Advance(d time.Duration)
+}
+
+// wallClock follows real time; Advance is a no-op.
+type wallClock struct{}
+
+func (wallClock) Now() time.Time          { return time.Now() }
+func (wallClock) Advance(d time.Duration) {}
+
+// VirtualClock starts at a fixed moment and moves only when advanced, so a
+// run is independent of how long each tick really took.
+type VirtualClock struct {
+	now time.Time
+}
+
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+func (c *VirtualClock) Now() time.Time          { return c.now }
+func (c *VirtualClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// clockFromConfig returns a virtual clock when a start time or speed-up is
+// configured, and the wall clock otherwise.
+func clockFromConfig(start string, speed float64) (Clock, error) {
+	if speed <= 0 {
+		return nil, fmt.Errorf("MOCK_SPEED must be positive, got %g", speed)
+	}
+	if start == "" {
+		if speed == 1 {
+			return wallClock{}, nil
+		}
+		return NewVirtualClock(time.Now().Truncate(time.Second)), nil
+	}
+	t, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MOCK_START_TIME %q: %w", start, err)
+	}
+	return NewVirtualClock(t), nil
+}
@@ -0,0 +1,189 @@
+package mockgen
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/railtime"
+)
+
+// loadReportInterval is how often load-test throughput is logged.
+const loadReportInterval = 10 * time.Second
+
+// loadTest paces publishing to a target rate and records how long each
+// publish took.
+type loadTest struct {
+	interval time.Duration
+	next     time.Time
+
+	mu        sync.Mutex
+	started   time.Time
+	sent      int
+	failed    int
+	window    []time.Duration
+	windowAt  time.Time
+	windowCnt int
+}
+
+func newLoadTest(eventsPerSec int) *loadTest {
+	now := time.Now()
+	lt := &loadTest{started: now, windowAt: now, next: now}
+	if eventsPerSec > 0 {
+		lt.interval = time.Second / time.Duration(eventsPerSec)
+	}
+	return lt
+}
+
+// publish runs fn, the publisher call for one event. In load-test mode it
+// waits for the event's slot first and records the outcome.
+func (m *MockGenerator) publish(fn func() error) error {
+	if m.load == nil {
+		return fn()
+	}
+
+	lt := m.load
+	if lt.interval > 0 {
+		if wait := time.Until(lt.next); wait > 0 {
+			time.Sleep(wait)
+		}
+		now := time.Now()
+		if lt.next.Before(now.Add(-lt.interval)) {
+			// Fell behind; do not burst to catch up.
+			lt.next = now
+		}
+		lt.next = lt.next.Add(lt.interval)
+	}
+
+	start := time.Now()
+	err := fn()
+	lt.record(time.Since(start), err)
+	return err
+}
+
+func (lt *loadTest) record(d time.Duration, err error) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lt.sent++
+	lt.windowCnt++
+	if err != nil {
+		lt.failed++
+	}
+	lt.window = append(lt.window, d)
+	if time.Since(lt.windowAt) >= loadReportInterval {
+		lt.reportLocked("Load test")
+	}
+}
+
+// reportLocked logs throughput and latency percentiles since the last
+// report, then starts a new window.
+func (lt *loadTest) reportLocked(label string) {
+	elapsed := time.Since(lt.windowAt).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(lt.windowCnt) / elapsed
+	}
+	target := "unpaced"
+	if lt.interval > 0 {
+		target = fmt.Sprintf("target %d/s", int(time.Second/lt.interval))
+	}
+	log.Printf("%s: %.1f events/s (%s), publish latency %s, %d sent, %d failed",
+		label, rate, target, percentiles(lt.window), lt.sent, lt.failed)
+
+	lt.window = lt.window[:0]
+	lt.windowAt = time.Now()
+	lt.windowCnt = 0
+}
+
+// summary logs the totals for the whole run.
+func (lt *loadTest) summary() {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	elapsed := time.Since(lt.started).Seconds()
+	if elapsed > 0 {
+		log.Printf("Load test finished: %d events in %.0fs (%.1f events/s), %d failed",
+			lt.sent, elapsed, float64(lt.sent)/elapsed, lt.failed)
+	}
+}
+
+func percentiles(ds []time.Duration) string {
+	if len(ds) == 0 {
+		return "n/a"
+	}
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i].Round(10 * time.Microsecond)
+	}
+	return fmt.Sprintf("p50=%s p90=%s p99=%s max=%s", at(0.5), at(0.9), at(0.99), sorted[len(sorted)-1].Round(10*time.Microsecond))
+}
+
+// synthesizeRoutes clones the loaded routes into n synthetic trains with
+// distinct numbers. Each clone leaves at a different time of day, runs a
+// little faster or slower, and is shifted slightly on the map so trains on
+// the same corridor do not sit on top of each other.
+func (m *MockGenerator) synthesizeRoutes(n int) []trainRoute {
+	if len(m.routes) == 0 {
+		return nil
+	}
+	out := make([]trainRoute, 0, n)
+	for i := 0; i < n; i++ {
+		base := m.routes[i%len(m.routes)]
+		shift := time.Duration(m.rng.Intn(24*60)) * time.Minute
+		stretch := 0.9 + 0.2*m.rng.Float64()
+		dLat := (m.rng.Float64() - 0.5) * 0.1
+		dLng := (m.rng.Float64() - 0.5) * 0.1
+
+		// RunsOn is left empty: the shift can move the departure to another
+		// day, so synthetic trains run daily.
+		clone := trainRoute{
+			TrainNumber: fmt.Sprintf("L%05d", i+1),
+			TrainName:   fmt.Sprintf("%s (load %d)", base.TrainName, i+1),
+			Type:        base.Type,
+			AvgSpeed:    base.AvgSpeed,
+			Stops:       make([]routeStop, len(base.Stops)),
+		}
+		epoch := time.Date(2000, 1, 1, 0, 0, 0, 0, railtime.IST)
+		sched := buildSchedule(base.Stops, epoch)
+		origin := sched[0].Dep
+		for j, stop := range base.Stops {
+			s := stop
+			s.Latitude += dLat
+			s.Longitude += dLng
+			s.ArrivalTime, s.DayNumber = shiftClock(stop.ArrivalTime, sched[j].Arr, epoch, origin, shift, stretch)
+			var depDay int
+			s.DepartureTime, depDay = shiftClock(stop.DepartureTime, sched[j].Dep, epoch, origin, shift, stretch)
+			if s.ArrivalTime == "" {
+				s.DayNumber = depDay
+			}
+			clone.Stops[j] = s
+		}
+		// The shift may push the departure past midnight; the run starts on
+		// day 1 regardless.
+		for j := range clone.Stops {
+			clone.Stops[j].DayNumber -= clone.Stops[0].DayNumber - 1
+		}
+		out = append(out, clone)
+	}
+	return out
+}
+
+// shiftClock moves a scheduled time to the clone's timetable: offset from the
+// run's origin, stretched, then shifted. epoch is midnight of the run's
+// first day. It returns the new "HH:MM" and day number, or "" when the
+// original had no time.
+func shiftClock(orig string, at, epoch, origin time.Time, shift time.Duration, stretch float64) (string, int) {
+	if orig == "" {
+		return "", 1
+	}
+	offset := time.Duration(float64(at.Sub(origin)) * stretch)
+	t := origin.Add(shift + offset)
+	return t.Format("15:04"), 1 + int(t.Sub(epoch)/(24*time.Hour))
+}
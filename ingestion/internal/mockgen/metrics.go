@@ -0,0 +1,32 @@
+package mockgen
+
+import (
+	"time"
+
+	"github.com/rail-app/ingestion/internal/metrics"
+)
+
+var (
+	cycleDuration = metrics.NewHistogram("ingestion_mock_cycle_duration_seconds",
+		"Time taken to simulate and publish one tick.", []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60})
+	cycleInterval = metrics.NewGauge("ingestion_mock_cycle_interval_seconds",
+		"Wall-clock time between ticks: PollInterval divided by MockSpeed.")
+	cycleOverruns = metrics.NewCounter("ingestion_mock_cycle_overruns_total",
+		"Ticks that took longer than the interval between them.")
+	runsInService = metrics.NewGauge("ingestion_mock_runs",
+		"Simulated train runs in service.")
+)
+
+// observeCycle records a tick that started at start. interval is zero in
+// load-test mode, where ticks run back to back.
+func observeCycle(start time.Time, interval time.Duration) {
+	took := time.Since(start)
+	cycleDuration.Observe(took.Seconds())
+	if interval <= 0 {
+		return
+	}
+	cycleInterval.Set(interval.Seconds())
+	if took > interval {
+		cycleOverruns.Inc()
+	}
+}
@@ -7,17 +7,23 @@ import (
 	"log"
 	"math"
 	"math/rand"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/lib/pq"
 
 	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/logging"
 	"github.com/rail-app/ingestion/internal/publisher"
+	"github.com/rail-app/ingestion/internal/railtime"
 )
 
 type trainRoute struct {
 	TrainNumber string
 	TrainName   string
+	Type        string
+	AvgSpeed    int    // km/h, 0 when unknown
+	RunsOn      string // days the train leaves its source, index 0 = Monday
 	Stops       []routeStop
 }
 
@@ -26,35 +32,135 @@ type routeStop struct {
 	StopNumber     int
 	ArrivalTime    string
 	DepartureTime  string
+	HaltMinutes    int
 	DistFromSource int
 	DayNumber      int
 	Platform       string
+	State          string
 	Latitude       float64
 	Longitude      float64
 }
 
+// eventPublisher is what the generator publishes through: a
+// *publisher.Publisher, or a recorder in tests.
+type eventPublisher interface {
+	PublishTrainPosition(ctx context.Context, pos publisher.TrainPosition) error
+	PublishPlatformChange(ctx context.Context, event publisher.PlatformChange) error
+	PublishDelayEvent(ctx context.Context, event publisher.DelayEvent) error
+	PublishRunEvent(ctx context.Context, event publisher.RunEvent) error
+	PublishPnrStatusChange(ctx context.Context, event publisher.PnrStatusChange) error
+	PublishServiceDisruption(ctx context.Context, event publisher.ServiceDisruption) error
+}
+
 type MockGenerator struct {
-	cfg    *config.Config
-	pub    *publisher.Publisher
+	cfg    atomic.Pointer[config.Config]
+	pub    eventPublisher
 	db     *sql.DB
 	routes []trainRoute
 	rng    *rand.Rand
+	clock  Clock
+
+	// scenario scripts disruptions; nil runs without one. Its offsets
+	// count from start.
+	scenario *Scenario
+	start    time.Time
+
+	// blocks keeps trains on shared sections apart; nil in load-test mode.
+	blocks *blockGraph
+
+	pnrs *pnrSim
+
+	// load is set in load-test mode.
+	load *loadTest
+
+	// runs are the runs in progress, in the order they started. started
+	// remembers every run begun so a finished run is not started again.
+	runs     []*trainRun
+	started  map[string]bool
+	caughtUp bool
+
+	// reload is signalled when SetConfig swaps in a new configuration.
+	reload chan struct{}
 }
 
 func New(cfg *config.Config, pub *publisher.Publisher) *MockGenerator {
-	return &MockGenerator{
-		cfg: cfg,
+	seed := int64(cfg.MockSeed)
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	m := &MockGenerator{
 		pub: pub,
-		rng: rand.New(rand.NewSource(time.Now().UnixNano())),
+		rng: rand.New(rand.NewSource(seed)),
+
+		started: make(map[string]bool),
+		pnrs:    newPNRSim(),
+		reload:  make(chan struct{}, 1),
+	}
+	m.cfg.Store(cfg)
+	return m
+}
+
+func (m *MockGenerator) config() *config.Config {
+	return m.cfg.Load()
+}
+
+// SetConfig switches the generator to a reloaded configuration. A new
+// PollInterval changes the simulated step from the next tick.
+func (m *MockGenerator) SetConfig(cfg *config.Config) {
+	m.cfg.Store(cfg)
+	select {
+	case m.reload <- struct{}{}:
+	default:
 	}
 }
 
-func (m *MockGenerator) Start(ctx context.Context) {
-	connStr := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		m.cfg.PostgresHost, m.cfg.PostgresPort,
-		m.cfg.PostgresUser, m.cfg.PostgresPassword, m.cfg.PostgresDB,
-	)
+// SetClock replaces the clock built from the config. It must be called
+// before Start.
+func (m *MockGenerator) SetClock(c Clock) {
+	m.clock = c
+}
+
+// simulationStart is what scenario offsets count from: MockStartTime when
+// set, so a replica taking over as leader replays the same script, or else
+// when Start was first called.
+func (m *MockGenerator) simulationStart() time.Time {
+	if m.start.IsZero() {
+		m.start = m.clock.Now()
+		if t, err := time.Parse(time.RFC3339, m.config().MockStartTime); err == nil {
+			m.start = t
+		}
+	}
+	return m.start
+}
+
+// Start runs the generator until ctx is cancelled. A pass already under way
+// runs under work and is allowed to finish; the caller cancels work when
+// its drain deadline passes. Start may be called again once it returns.
+func (m *MockGenerator) Start(ctx, work context.Context) {
+	if m.clock == nil {
+		clock, err := clockFromConfig(m.config().MockStartTime, m.config().MockSpeed)
+		if err != nil {
+			log.Printf("Mock generator not started: %v", err)
+			return
+		}
+		m.clock = clock
+	}
+
+	if m.config().MockScenario != "" {
+		sc, err := LoadScenario(m.config().MockScenario, m.simulationStart())
+		if err != nil {
+			log.Printf("Mock generator not started: %v", err)
+			return
+		}
+		m.scenario = sc
+		log.Printf("Loaded mock scenario %q with %d event(s)", sc.Name, len(sc.Events))
+	}
+
+	// A restarted generator, such as a replica taking over as leader,
+	// catches up on the day quietly rather than replaying where it left off.
+	m.runs, m.started, m.caughtUp = nil, make(map[string]bool), false
+
+	connStr := m.config().PostgresDSN()
 
 	var err error
 	for i := 0; i < 30; i++ {
@@ -65,7 +171,11 @@ func (m *MockGenerator) Start(ctx context.Context) {
 			}
 		}
 		log.Println("Waiting for database connection...")
-		time.Sleep(2 * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
 	}
 
 	if err != nil {
@@ -82,11 +192,26 @@ func (m *MockGenerator) Start(ctx context.Context) {
 
 	log.Printf("Loaded %d train routes for mock generation", len(m.routes))
 
-	ticker := time.NewTicker(time.Duration(m.cfg.PollInterval) * time.Second)
+	if m.config().MockLoadTrains > 0 {
+		m.runLoadTest(ctx, work)
+		return
+	}
+
+	m.blocks = newBlockGraph(m.routes)
+	log.Printf("Built %d block sections from the routes", len(m.blocks.sections))
+
+	// Each tick covers PollInterval of simulated time; with a speed-up the
+	// ticks come correspondingly faster.
+	step := time.Duration(m.config().PollInterval) * time.Second
+	ticker := time.NewTicker(time.Duration(float64(step) / m.config().MockSpeed))
 	defer ticker.Stop()
 
+	if m.config().MockSpeed != 1 {
+		log.Printf("Simulating from %s at %gx real time", m.clock.Now().Format(time.RFC3339), m.config().MockSpeed)
+	}
+
 	// Initial generation
-	m.generateAll(ctx)
+	m.generateAll(work)
 
 	for {
 		select {
@@ -94,14 +219,44 @@ func (m *MockGenerator) Start(ctx context.Context) {
 			log.Println("Mock generator stopping...")
 			return
 		case <-ticker.C:
-			m.generateAll(ctx)
+			m.clock.Advance(step)
+			m.generateAll(work)
+		case <-m.reload:
+			step = time.Duration(m.config().PollInterval) * time.Second
+			ticker.Reset(time.Duration(float64(step) / m.config().MockSpeed))
 		}
 	}
 }
 
+// runLoadTest replaces the routes with MockLoadTrains synthetic trains and
+// simulates them back to back, one PollInterval of simulated time per pass,
+// with publishing paced to MockLoadRate events per second. Each pass runs
+// under work, so a cancelled ctx ends the test after the current pass.
+func (m *MockGenerator) runLoadTest(ctx, work context.Context) {
+	m.routes = m.synthesizeRoutes(m.config().MockLoadTrains)
+	if len(m.routes) == 0 {
+		log.Println("Load test needs at least one route to clone")
+		return
+	}
+	if _, ok := m.clock.(wallClock); ok {
+		m.clock = NewVirtualClock(time.Now().Truncate(time.Second))
+	}
+	m.load = newLoadTest(m.config().MockLoadRate)
+	defer m.load.summary()
+
+	log.Printf("Load test: %d synthetic trains, target %d events/s", len(m.routes), m.config().MockLoadRate)
+
+	step := time.Duration(m.config().PollInterval) * time.Second
+	for ctx.Err() == nil {
+		m.generateAll(work)
+		m.clock.Advance(step)
+	}
+}
+
 func (m *MockGenerator) loadRoutes() error {
 	rows, err := m.db.Query(`
-		SELECT DISTINCT t.number, t.name
+		SELECT DISTINCT t.number, t.name, COALESCE(t.type, ''), COALESCE(t.avg_speed_kmph, 0),
+			COALESCE(t.runs_on, '')
 		FROM trains t
 		INNER JOIN train_routes tr ON tr.train_number = t.number
 		ORDER BY t.number
@@ -111,25 +266,20 @@ func (m *MockGenerator) loadRoutes() error {
 	}
 	defer rows.Close()
 
-	var trainNumbers []struct {
-		Number string
-		Name   string
-	}
+	var trains []trainRoute
 	for rows.Next() {
-		var tn struct {
-			Number string
-			Name   string
-		}
-		if err := rows.Scan(&tn.Number, &tn.Name); err != nil {
+		var t trainRoute
+		if err := rows.Scan(&t.TrainNumber, &t.TrainName, &t.Type, &t.AvgSpeed, &t.RunsOn); err != nil {
 			continue
 		}
-		trainNumbers = append(trainNumbers, tn)
+		trains = append(trains, t)
 	}
 
-	for _, tn := range trainNumbers {
-		route, err := m.loadTrainRoute(tn.Number, tn.Name)
+	m.routes = nil
+	for _, t := range trains {
+		route, err := m.loadTrainRoute(t)
 		if err != nil {
-			log.Printf("Failed to load route for %s: %v", tn.Number, err)
+			log.Printf("Failed to load route for %s: %v", t.TrainNumber, err)
 			continue
 		}
 		if len(route.Stops) > 0 {
@@ -140,36 +290,32 @@ func (m *MockGenerator) loadRoutes() error {
 	return nil
 }
 
-func (m *MockGenerator) loadTrainRoute(trainNumber, trainName string) (trainRoute, error) {
+func (m *MockGenerator) loadTrainRoute(route trainRoute) (trainRoute, error) {
 	rows, err := m.db.Query(`
 		SELECT tr.station_code, tr.stop_number,
 			   COALESCE(tr.arrival_time::text, ''),
 			   COALESCE(tr.departure_time::text, ''),
+			   COALESCE(tr.halt_minutes, 0),
 			   tr.distance_from_source, tr.day_number,
-			   COALESCE(tr.platform, ''),
+			   COALESCE(tr.platform, ''), COALESCE(s.state, ''),
 			   COALESCE(s.latitude, 0), COALESCE(s.longitude, 0)
 		FROM train_routes tr
 		JOIN stations s ON s.code = tr.station_code
 		WHERE tr.train_number = $1
 		ORDER BY tr.stop_number ASC
-	`, trainNumber)
+	`, route.TrainNumber)
 	if err != nil {
 		return trainRoute{}, err
 	}
 	defer rows.Close()
 
-	route := trainRoute{
-		TrainNumber: trainNumber,
-		TrainName:   trainName,
-	}
-
 	for rows.Next() {
 		var stop routeStop
 		if err := rows.Scan(
 			&stop.StationCode, &stop.StopNumber,
-			&stop.ArrivalTime, &stop.DepartureTime,
+			&stop.ArrivalTime, &stop.DepartureTime, &stop.HaltMinutes,
 			&stop.DistFromSource, &stop.DayNumber,
-			&stop.Platform, &stop.Latitude, &stop.Longitude,
+			&stop.Platform, &stop.State, &stop.Latitude, &stop.Longitude,
 		); err != nil {
 			log.Printf("Failed to scan stop: %v", err)
 			continue
@@ -181,120 +327,121 @@ func (m *MockGenerator) loadTrainRoute(trainNumber, trainName string) (trainRout
 }
 
 func (m *MockGenerator) generateAll(ctx context.Context) {
-	now := time.Now()
-	log.Printf("Generating mock data at %s for %d trains", now.Format(time.RFC3339), len(m.routes))
+	var interval time.Duration
+	if m.load == nil {
+		interval = time.Duration(float64(time.Duration(m.config().PollInterval)*time.Second) / m.config().MockSpeed)
+	}
+	defer observeCycle(time.Now(), interval)
 
-	for _, route := range m.routes {
+	now := m.clock.Now()
+	catchUp := !m.caughtUp
+	m.startRuns(ctx, now)
+	if m.load == nil {
+		log.Printf("Generating mock data at %s for %d running trains", now.Format(time.RFC3339), len(m.runs))
+	}
+
+	m.advanceAll(ctx, m.runs, now, !catchUp)
+
+	active := m.runs[:0]
+	for _, r := range m.runs {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			m.generateTrainPosition(ctx, route, now)
 		}
-	}
-}
 
-func (m *MockGenerator) generateTrainPosition(ctx context.Context, route trainRoute, now time.Time) {
-	if len(route.Stops) < 2 {
-		return
+		// Runs that finished before startup are not news.
+		if r.done && catchUp {
+			continue
+		}
+		m.announcePlatformSwap(ctx, r, now)
+		m.publishPosition(ctx, r, now)
+		if !r.done {
+			active = append(active, r)
+		}
 	}
+	m.runs = active
+	runsInService.Set(float64(len(m.runs)))
 
-	// Simulate train position between two stops
-	// Pick a random segment of the route
-	segIdx := m.rng.Intn(len(route.Stops) - 1)
-	fromStop := route.Stops[segIdx]
-	toStop := route.Stops[segIdx+1]
+	m.simulatePNRs(ctx, now)
+}
 
-	// Random progress between stops (0.0 to 1.0)
-	progress := m.rng.Float64()
+// startRuns begins every run whose scheduled departure has passed on a day
+// the train runs. On the first call that includes runs that left on earlier
+// days and are still under way; generateAll fast-forwards them to now
+// without publishing. A train with no run in service publishes nothing.
+func (m *MockGenerator) startRuns(ctx context.Context, now time.Time) {
+	today := now.In(railtime.IST)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, railtime.IST)
+
+	for i := range m.routes {
+		route := &m.routes[i]
+		if len(route.Stops) < 2 {
+			continue
+		}
+		days := route.Stops[len(route.Stops)-1].DayNumber
+		for back := days; back >= 0; back-- {
+			date := today.AddDate(0, 0, -back)
+			if !railtime.RunsOnDay(route.RunsOn, date) {
+				continue
+			}
+			r := newTrainRun(route, date)
+			if m.started[r.key()] || r.sched[0].Dep.After(now) {
+				continue
+			}
+			m.started[r.key()] = true
 
-	lat := fromStop.Latitude + (toStop.Latitude-fromStop.Latitude)*progress
-	lng := fromStop.Longitude + (toStop.Longitude-fromStop.Longitude)*progress
+			if m.scenario.cancelled(route.TrainNumber, r.startDate, r.sched[0].Dep) {
+				if m.caughtUp {
+					m.publishCancellation(ctx, r, now)
+				}
+				continue
+			}
 
-	// Add some noise
-	lat += (m.rng.Float64() - 0.5) * 0.01
-	lng += (m.rng.Float64() - 0.5) * 0.01
+			m.begin(r)
+			m.runs = append(m.runs, r)
+		}
+	}
 
-	// Random speed between 40 and 130 kmph
-	speed := 40 + m.rng.Intn(90)
+	m.caughtUp = true
 
-	// Random delay (mostly 0, sometimes up to 120 min)
-	delay := 0
-	if m.rng.Float64() < 0.3 {
-		delay = m.rng.Intn(120)
+	// Forget runs old enough that they can no longer be restarted.
+	for key := range m.started {
+		if date, err := time.ParseInLocation("2006-01-02", key[len(key)-10:], railtime.IST); err == nil && today.Sub(date) > 7*24*time.Hour {
+			delete(m.started, key)
+		}
 	}
+}
 
-	// Calculate ETA to next station
-	distToNext := float64(toStop.DistFromSource-fromStop.DistFromSource) * (1 - progress)
-	etaMinutes := 0
-	if speed > 0 {
-		etaMinutes = int(math.Round(distToNext / float64(speed) * 60))
+func (m *MockGenerator) publishCancellation(ctx context.Context, r *trainRun, now time.Time) {
+	codes := make([]string, len(r.route.Stops))
+	for i, stop := range r.route.Stops {
+		codes[i] = stop.StationCode
 	}
-	etaNext := now.Add(time.Duration(etaMinutes) * time.Minute).Format(time.RFC3339)
-
-	pos := publisher.TrainPosition{
-		TrainNumber:    route.TrainNumber,
-		Latitude:       math.Round(lat*10000000) / 10000000,
-		Longitude:      math.Round(lng*10000000) / 10000000,
-		SpeedKmph:      speed,
-		DelayMinutes:   delay,
-		CurrentStation: fromStop.StationCode,
-		NextStation:    toStop.StationCode,
-		ETANext:        etaNext,
-		Timestamp:      now.Format(time.RFC3339),
+	ev := publisher.ServiceDisruption{
+		EventType:        "service_disruption",
+		TrainNumber:      r.route.TrainNumber,
+		DisruptionType:   "cancelled",
+		AffectedStations: codes,
+		Details:          fmt.Sprintf("Run of %s cancelled", r.startDate.Format("02-Jan-2006")),
+		Timestamp:        now.Format(time.RFC3339),
 	}
-
-	if err := m.pub.PublishTrainPosition(ctx, pos); err != nil {
-		log.Printf("Failed to publish position for %s: %v", route.TrainNumber, err)
+	if err := m.publish(func() error { return m.pub.PublishServiceDisruption(ctx, ev) }); err != nil {
+		log.Printf("Failed to publish cancellation for %s: %v", r.route.TrainNumber, err)
 	} else {
-		log.Printf("Published position for %s (%s): %.4f,%.4f speed=%d delay=%d",
-			route.TrainNumber, route.TrainName, lat, lng, speed, delay)
-	}
-
-	// Occasionally generate delay events
-	if delay > 10 && m.rng.Float64() < 0.5 {
-		causes := []string{
-			"Fog/Low Visibility",
-			"Signal Failure",
-			"Track Maintenance",
-			"Congestion",
-			"Waiting for Crossing",
-			"Late Departure",
-			"Technical Issue",
-			"Caution Order",
-		}
-
-		delayEvent := publisher.DelayEvent{
-			TrainNumber:   route.TrainNumber,
-			StationCode:   fromStop.StationCode,
-			ScheduledTime: now.Add(-time.Duration(delay) * time.Minute).Format(time.RFC3339),
-			ActualTime:    now.Format(time.RFC3339),
-			DelayMinutes:  delay,
-			Cause:         causes[m.rng.Intn(len(causes))],
-			Timestamp:     now.Format(time.RFC3339),
-		}
-
-		if err := m.pub.PublishDelayEvent(ctx, delayEvent); err != nil {
-			log.Printf("Failed to publish delay event for %s: %v", route.TrainNumber, err)
-		}
+		log.Printf("Cancelled %s (%s) run of %s", r.route.TrainNumber, r.route.TrainName, r.startDate.Format("2006-01-02"))
 	}
+}
 
-	// Occasionally generate platform changes
-	if m.rng.Float64() < 0.2 {
-		platformEvent := publisher.PlatformChange{
-			StationCode:    fromStop.StationCode,
-			PlatformNumber: fromStop.Platform,
-			TrainNumber:    route.TrainNumber,
-			EventType:      "arrival",
-			Timestamp:      now.Format(time.RFC3339),
-		}
+func (m *MockGenerator) publishPosition(ctx context.Context, r *trainRun, now time.Time) {
+	pos := r.position(now)
+	pos.Latitude = math.Round(pos.Latitude*10000000) / 10000000
+	pos.Longitude = math.Round(pos.Longitude*10000000) / 10000000
 
-		if platformEvent.PlatformNumber == "" {
-			platformEvent.PlatformNumber = fmt.Sprintf("%d", 1+m.rng.Intn(10))
-		}
-
-		if err := m.pub.PublishPlatformChange(ctx, platformEvent); err != nil {
-			log.Printf("Failed to publish platform event: %v", err)
-		}
+	if err := m.publish(func() error { return m.pub.PublishTrainPosition(ctx, pos) }); err != nil {
+		log.Printf("Failed to publish position for %s: %v", r.route.TrainNumber, err)
+	} else if m.load == nil {
+		logging.Debugf("Published position for %s (%s): %.4f,%.4f speed=%d delay=%d",
+			r.route.TrainNumber, r.route.TrainName, pos.Latitude, pos.Longitude, pos.SpeedKmph, pos.DelayMinutes)
 	}
 }
@@ -0,0 +1,136 @@
+package mockgen
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/publisher"
+	"github.com/rail-app/ingestion/internal/railtime"
+)
+
+// recorder is an eventPublisher that writes every event it is given, in
+// order, as one line of JSON prefixed with its kind.
+type recorder struct {
+	buf bytes.Buffer
+}
+
+func (r *recorder) record(kind string, event interface{}) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&r.buf, "%s %s\n", kind, data)
+	return nil
+}
+
+func (r *recorder) PublishTrainPosition(_ context.Context, pos publisher.TrainPosition) error {
+	return r.record("position", pos)
+}
+
+func (r *recorder) PublishPlatformChange(_ context.Context, event publisher.PlatformChange) error {
+	return r.record("platform", event)
+}
+
+func (r *recorder) PublishDelayEvent(_ context.Context, event publisher.DelayEvent) error {
+	return r.record("delay", event)
+}
+
+func (r *recorder) PublishRunEvent(_ context.Context, event publisher.RunEvent) error {
+	return r.record("run", event)
+}
+
+func (r *recorder) PublishPnrStatusChange(_ context.Context, event publisher.PnrStatusChange) error {
+	return r.record("pnr", event)
+}
+
+func (r *recorder) PublishServiceDisruption(_ context.Context, event publisher.ServiceDisruption) error {
+	return r.record("disruption", event)
+}
+
+// testRoutes are two trains of different classes sharing their first two
+// block sections, so runs meet in the block graph.
+func testRoutes() []trainRoute {
+	return []trainRoute{
+		{
+			TrainNumber: "12302", TrainName: "New Delhi Howrah Rajdhani", Type: "Rajdhani", AvgSpeed: 85,
+			Stops: []routeStop{
+				{StationCode: "NDLS", StopNumber: 1, DepartureTime: "06:50:00", DayNumber: 1, Platform: "3", State: "Delhi", Latitude: 28.6430, Longitude: 77.2194},
+				{StationCode: "GZB", StopNumber: 2, ArrivalTime: "07:25:00", DepartureTime: "07:27:00", HaltMinutes: 2, DistFromSource: 25, DayNumber: 1, Platform: "2", State: "Uttar Pradesh", Latitude: 28.6497, Longitude: 77.4335},
+				{StationCode: "ALJN", StopNumber: 3, ArrivalTime: "08:45:00", DepartureTime: "08:47:00", HaltMinutes: 2, DistFromSource: 131, DayNumber: 1, Platform: "1", State: "Uttar Pradesh", Latitude: 27.8845, Longitude: 78.0859},
+				{StationCode: "CNB", StopNumber: 4, ArrivalTime: "11:35:00", DistFromSource: 440, DayNumber: 1, Platform: "1", State: "Uttar Pradesh", Latitude: 26.4540, Longitude: 80.3517},
+			},
+		},
+		{
+			TrainNumber: "54473", TrainName: "Delhi Aligarh Passenger", Type: "Passenger", AvgSpeed: 40,
+			Stops: []routeStop{
+				{StationCode: "NDLS", StopNumber: 1, DepartureTime: "06:40:00", DayNumber: 1, Platform: "5", State: "Delhi", Latitude: 28.6430, Longitude: 77.2194},
+				{StationCode: "GZB", StopNumber: 2, ArrivalTime: "07:30:00", DepartureTime: "07:35:00", HaltMinutes: 5, DistFromSource: 25, DayNumber: 1, Platform: "4", State: "Uttar Pradesh", Latitude: 28.6497, Longitude: 77.4335},
+				{StationCode: "ALJN", StopNumber: 3, ArrivalTime: "10:10:00", DistFromSource: 131, DayNumber: 1, Platform: "3", State: "Uttar Pradesh", Latitude: 27.8845, Longitude: 78.0859},
+			},
+		},
+	}
+}
+
+// simulate runs a generator with a fixed seed on a virtual clock for a day
+// of simulated time and returns everything it published. It drives
+// generateAll directly as Start would, without loading routes from the
+// database; the PNR lookups go to a database that refuses connections and
+// find nothing.
+func simulate(t *testing.T) []byte {
+	t.Helper()
+
+	cfg := config.Defaults()
+	cfg.MockSeed = 20261019
+	cfg.PollInterval = 60
+
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	rec := &recorder{}
+	m := New(cfg, nil)
+	m.pub = rec
+	m.db = db
+	m.SetClock(NewVirtualClock(time.Date(2026, 10, 19, 5, 0, 0, 0, railtime.IST)))
+	m.routes = testRoutes()
+	m.blocks = newBlockGraph(m.routes)
+
+	step := time.Duration(cfg.PollInterval) * time.Second
+	for i := 0; i < 24*60; i++ {
+		m.generateAll(context.Background())
+		m.clock.Advance(step)
+	}
+	return rec.buf.Bytes()
+}
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	first := simulate(t)
+	second := simulate(t)
+
+	if len(first) == 0 {
+		t.Fatal("generator published nothing")
+	}
+	for _, want := range []string{`"event_type":"run_started"`, `"event_type":"run_ended"`, "position {"} {
+		if !bytes.Contains(first, []byte(want)) {
+			t.Errorf("published stream has no %s", want)
+		}
+	}
+
+	if !bytes.Equal(first, second) {
+		a, b := bytes.Split(first, []byte("\n")), bytes.Split(second, []byte("\n"))
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if !bytes.Equal(a[i], b[i]) {
+				t.Fatalf("streams diverge at event %d:\n first: %s\nsecond: %s", i, a[i], b[i])
+			}
+		}
+		t.Fatalf("streams differ in length: %d and %d events", len(a), len(b))
+	}
+}
@@ -0,0 +1,354 @@
+package mockgen
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/publisher"
+	"github.com/rail-app/ingestion/internal/railtime"
+)
+
+const (
+	// chartLead is how long before the train leaves its source the
+	// reservation chart is prepared and berths are allotted.
+	chartLead = 4 * time.Hour
+	// racSlots is the length of the RAC queue ahead of the waitlist.
+	racSlots = 20
+	// maxInitialQueue bounds a new booking's place in the RAC + WL queue.
+	maxInitialQueue = 80
+	// cancelProbability is the chance a booking is cancelled before the
+	// chart is prepared.
+	cancelProbability = 0.05
+	// pnrReloadTicks is how often, in ticks, watched PNRs and journeys are
+	// re-read from the database.
+	pnrReloadTicks = 10
+)
+
+// PNR statuses, as shown by the enquiry system.
+const (
+	pnrConfirmed = "CNF"
+	pnrCancelled = "CAN"
+)
+
+// berthLayouts gives the berth type for each position in a coach bay.
+var berthLayouts = map[string][]string{
+	"SL":  {"LB", "MB", "UB", "LB", "MB", "UB", "SL", "SU"},
+	"3AC": {"LB", "MB", "UB", "LB", "MB", "UB", "SL", "SU"},
+	"2AC": {"LB", "UB", "LB", "UB", "SL", "SU"},
+	"1AC": {"LB", "UB", "LB", "UB"},
+}
+
+// classCoachTypes maps booking classes to coach_compositions coach types.
+var classCoachTypes = map[string]string{
+	"1A": "1AC", "2A": "2AC", "3A": "3AC", "SL": "SL", "CC": "CC", "EC": "EC",
+	"1AC": "1AC", "2AC": "2AC", "3AC": "3AC",
+}
+
+type coach struct {
+	Label  string
+	Type   string
+	Berths int
+}
+
+// booking is one simulated PNR. Its place in the combined RAC + waitlist
+// queue falls from start to final between first sight and chart
+// preparation; position 0 is confirmed.
+type booking struct {
+	PNR        string
+	Train      string
+	TravelDate time.Time
+	Class      string
+
+	firstSeen time.Time
+	chartAt   time.Time
+	start     int
+	final     int
+	cancelAt  time.Time // zero unless the booking will be cancelled
+	status    string
+	coach     string
+	berth     string
+	charted   bool
+}
+
+// pnrSim drives the bookings of the mock PNR lifecycle.
+type pnrSim struct {
+	bookings map[string]*booking
+	coaches  map[string][]coach
+	taken    map[string]map[string]bool // train|coach|berth already allotted, by travel date
+	ticks    int
+}
+
+func newPNRSim() *pnrSim {
+	return &pnrSim{
+		bookings: make(map[string]*booking),
+		coaches:  make(map[string][]coach),
+		taken:    make(map[string]map[string]bool),
+	}
+}
+
+// simulatePNRs advances every booking to now and publishes status changes.
+func (m *MockGenerator) simulatePNRs(ctx context.Context, now time.Time) {
+	if m.pnrs.ticks%pnrReloadTicks == 0 {
+		if err := m.loadBookings(now); err != nil {
+			log.Printf("Failed to load PNRs for mock lifecycle: %v", err)
+		}
+	}
+	m.pnrs.ticks++
+
+	pnrs := make([]string, 0, len(m.pnrs.bookings))
+	for pnr := range m.pnrs.bookings {
+		pnrs = append(pnrs, pnr)
+	}
+	sort.Strings(pnrs)
+
+	for _, pnr := range pnrs {
+		b := m.pnrs.bookings[pnr]
+		old := b.status
+		m.stepBooking(b, now)
+		if b.status != old {
+			m.publishPNR(ctx, b, old, now)
+		}
+		// Travel is over; nothing more will change.
+		if now.Sub(b.TravelDate) > 48*time.Hour {
+			delete(m.pnrs.bookings, pnr)
+			// Every booking for the date is past this point together, and
+			// no new ones are loaded for it, so its berths can go too.
+			delete(m.pnrs.taken, b.TravelDate.Format("2006-01-02"))
+		}
+	}
+}
+
+// stepBooking moves b to where it should be at now.
+func (m *MockGenerator) stepBooking(b *booking, now time.Time) {
+	if b.status == pnrCancelled || b.charted {
+		return
+	}
+	if !b.cancelAt.IsZero() && !now.Before(b.cancelAt) {
+		b.status = pnrCancelled
+		return
+	}
+	if !now.Before(b.chartAt) {
+		m.prepareChart(b)
+		return
+	}
+
+	// Interpolate the queue position towards its final value as the chart
+	// approaches.
+	total := b.chartAt.Sub(b.firstSeen)
+	left := b.chartAt.Sub(now)
+	pos := b.final
+	if total > 0 {
+		pos += int(math.Ceil(float64(b.start-b.final) * float64(left) / float64(total)))
+	}
+	b.status = queueStatus(pos)
+}
+
+// prepareChart settles the booking: confirmed and RAC passengers get a
+// coach and berth, and anyone still waitlisted is cancelled.
+func (m *MockGenerator) prepareChart(b *booking) {
+	b.charted = true
+	if b.final > racSlots {
+		b.status = pnrCancelled
+		return
+	}
+
+	b.coach, b.berth = m.allotBerth(b, b.final > 0)
+	if b.final == 0 {
+		b.status = pnrConfirmed
+	} else {
+		b.status = queueStatus(b.final)
+	}
+}
+
+func queueStatus(pos int) string {
+	switch {
+	case pos <= 0:
+		return pnrConfirmed
+	case pos <= racSlots:
+		return fmt.Sprintf("RAC %d", pos)
+	default:
+		return fmt.Sprintf("WL %d", pos-racSlots)
+	}
+}
+
+// allotBerth picks a free berth in a coach of the booking's class. RAC
+// passengers share a side lower berth.
+func (m *MockGenerator) allotBerth(b *booking, rac bool) (string, string) {
+	coaches := m.pnrs.coaches[b.Train]
+	var eligible []coach
+	want := classCoachTypes[strings.ToUpper(b.Class)]
+	for _, c := range coaches {
+		if c.Berths > 0 && (want == "" || c.Type == want) {
+			eligible = append(eligible, c)
+		}
+	}
+	if len(eligible) == 0 {
+		for _, c := range coaches {
+			if c.Berths > 0 {
+				eligible = append(eligible, c)
+			}
+		}
+	}
+	if len(eligible) == 0 {
+		return "", ""
+	}
+
+	date := b.TravelDate.Format("2006-01-02")
+	taken := m.pnrs.taken[date]
+	if taken == nil {
+		taken = make(map[string]bool)
+		m.pnrs.taken[date] = taken
+	}
+	for attempt := 0; attempt < 50; attempt++ {
+		c := eligible[m.rng.Intn(len(eligible))]
+		layout := berthLayouts[c.Type]
+		n := 1 + m.rng.Intn(c.Berths)
+		if rac {
+			if sides := sideLowers(layout, c.Berths); len(sides) > 0 {
+				n = sides[m.rng.Intn(len(sides))]
+			}
+		}
+		key := b.Train + "|" + c.Label + "|" + fmt.Sprint(n)
+		if taken[key] && !rac {
+			continue
+		}
+		taken[key] = true
+
+		berth := fmt.Sprint(n)
+		if len(layout) > 0 {
+			berth += "/" + layout[(n-1)%len(layout)]
+		}
+		return c.Label, berth
+	}
+	return eligible[0].Label, ""
+}
+
+// sideLowers lists the side lower berth numbers in a coach.
+func sideLowers(layout []string, berths int) []int {
+	var sides []int
+	for n := 1; n <= berths && len(layout) > 0; n++ {
+		if layout[(n-1)%len(layout)] == "SL" {
+			sides = append(sides, n)
+		}
+	}
+	return sides
+}
+
+func (m *MockGenerator) publishPNR(ctx context.Context, b *booking, old string, now time.Time) {
+	ev := publisher.PnrStatusChange{
+		PNR:       b.PNR,
+		OldStatus: old,
+		NewStatus: b.status,
+		Coach:     b.coach,
+		Berth:     b.berth,
+		Timestamp: now.Format(time.RFC3339),
+	}
+	if err := m.publish(func() error { return m.pub.PublishPnrStatusChange(ctx, ev) }); err != nil {
+		log.Printf("Failed to publish PNR status for %s: %v", b.PNR, err)
+	} else if m.load == nil {
+		log.Printf("PNR %s (%s): %s -> %s %s %s", b.PNR, b.Train, old, b.status, b.coach, b.berth)
+	}
+}
+
+// loadBookings picks up watched PNRs and journeys not seen before. A
+// journey that already has a coach and berth is confirmed and left alone.
+func (m *MockGenerator) loadBookings(now time.Time) error {
+	rows, err := m.db.Query(`
+		SELECT pnr, train_number, travel_date, COALESCE(class, '')
+		FROM journeys
+		WHERE pnr IS NOT NULL AND pnr <> ''
+		  AND train_number IS NOT NULL
+		  AND (coach IS NULL OR coach = '' OR berth IS NULL OR berth = '')
+		  AND travel_date >= $1
+		UNION
+		SELECT pnr, train_number, travel_date, ''
+		FROM pnr_watchlist
+		WHERE train_number IS NOT NULL AND travel_date IS NOT NULL
+		  AND travel_date >= $1
+		ORDER BY 1, 4 DESC
+	`, now.In(railtime.IST).AddDate(0, 0, -1).Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b booking
+		if err := rows.Scan(&b.PNR, &b.Train, &b.TravelDate, &b.Class); err != nil {
+			log.Printf("Failed to scan booking: %v", err)
+			continue
+		}
+		if _, ok := m.pnrs.bookings[b.PNR]; ok {
+			continue
+		}
+		b.TravelDate = time.Date(b.TravelDate.Year(), b.TravelDate.Month(), b.TravelDate.Day(), 0, 0, 0, 0, railtime.IST)
+		if err := m.ensureCoaches(b.Train); err != nil {
+			log.Printf("Failed to load coaches for %s: %v", b.Train, err)
+		}
+		m.newBooking(&b, now)
+		m.pnrs.bookings[b.PNR] = &b
+	}
+	return rows.Err()
+}
+
+// newBooking draws the booking's starting queue position, how far the queue
+// will clear by chart time, and whether it will be cancelled.
+func (m *MockGenerator) newBooking(b *booking, now time.Time) {
+	b.firstSeen = now
+	b.chartAt = m.sourceDeparture(b.Train, b.TravelDate).Add(-chartLead)
+
+	b.start = m.rng.Intn(maxInitialQueue + 1)
+	clearance := 0.5 + 0.6*m.rng.Float64()
+	b.final = int(math.Round(float64(b.start) * (1 - clearance)))
+	if b.final < 0 {
+		b.final = 0
+	}
+	if m.rng.Float64() < cancelProbability && b.chartAt.After(now) {
+		b.cancelAt = now.Add(time.Duration(m.rng.Int63n(int64(b.chartAt.Sub(now)))))
+	}
+}
+
+// sourceDeparture is when the train leaves its source on date, or midnight
+// when the route is unknown.
+func (m *MockGenerator) sourceDeparture(train string, date time.Time) time.Time {
+	for i := range m.routes {
+		if m.routes[i].TrainNumber == train && len(m.routes[i].Stops) > 0 {
+			if t, ok := clockOn(date, m.routes[i].Stops[0].DepartureTime, 1); ok {
+				return t
+			}
+		}
+	}
+	return date
+}
+
+func (m *MockGenerator) ensureCoaches(train string) error {
+	if _, ok := m.pnrs.coaches[train]; ok {
+		return nil
+	}
+	rows, err := m.db.Query(`
+		SELECT coach_label, coach_type, COALESCE(total_berths, 0)
+		FROM coach_compositions
+		WHERE train_number = $1
+		ORDER BY position
+	`, train)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var coaches []coach
+	for rows.Next() {
+		var c coach
+		if err := rows.Scan(&c.Label, &c.Type, &c.Berths); err != nil {
+			return err
+		}
+		coaches = append(coaches, c)
+	}
+	m.pnrs.coaches[train] = coaches
+	return rows.Err()
+}
@@ -0,0 +1,45 @@
+package mockgen
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/railtime"
+)
+
+func TestFinishedBookingsFreeTheirBerths(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, railtime.IST)
+	past := time.Date(2026, 10, 16, 0, 0, 0, 0, railtime.IST)
+	next := time.Date(2026, 10, 20, 0, 0, 0, 0, railtime.IST)
+
+	m := New(config.Defaults(), nil)
+	m.pub = &recorder{}
+	m.pnrs.coaches["12302"] = []coach{{Label: "B1", Type: "3AC", Berths: 72}}
+	for _, b := range []*booking{
+		{PNR: "1000000001", Train: "12302", TravelDate: past, Class: "3A"},
+		{PNR: "1000000002", Train: "12302", TravelDate: past, Class: "3A"},
+		{PNR: "1000000003", Train: "12302", TravelDate: next, Class: "3A"},
+	} {
+		m.prepareChart(b)
+		m.pnrs.bookings[b.PNR] = b
+	}
+	if len(m.pnrs.taken) != 2 {
+		t.Fatalf("berths allotted on %d dates, want 2", len(m.pnrs.taken))
+	}
+
+	// Skip the reload tick; there is no database behind this generator.
+	m.pnrs.ticks = 1
+	m.simulatePNRs(context.Background(), now)
+
+	if _, ok := m.pnrs.bookings["1000000003"]; !ok || len(m.pnrs.bookings) != 1 {
+		t.Fatalf("bookings left = %d, want only the upcoming one", len(m.pnrs.bookings))
+	}
+	if _, ok := m.pnrs.taken[past.Format("2006-01-02")]; ok {
+		t.Error("berths of a finished date are still held")
+	}
+	if got := len(m.pnrs.taken[next.Format("2006-01-02")]); got != 1 {
+		t.Errorf("berths held for the upcoming date = %d, want 1", got)
+	}
+}
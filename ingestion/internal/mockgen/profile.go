@@ -0,0 +1,147 @@
+package mockgen
+
+import (
+	"math"
+	"strings"
+)
+
+// trainProfile is how a class of train moves and runs late.
+type trainProfile struct {
+	Class string
+
+	MaxSpeed   float64 // km/h, the sectional speed limit for the class
+	Accel      float64 // km/h gained per minute pulling away
+	Decel      float64 // km/h shed per minute braking
+	SpeedNoise float64 // relative spread of running times and reported speed
+
+	LateStart    float64 // chance of leaving the source late
+	IncidentRate float64 // chance of an incident per section
+	IncidentMax  int     // worst incident, minutes
+	Recovery     float64 // share of a section a late train can make up
+}
+
+// Profiles by class. Premium trains get path priority, so they are rarely
+// held and recover well; passenger trains give way to everything.
+var (
+	profileVandeBharat = trainProfile{
+		Class: "semi-high-speed", MaxSpeed: 160, Accel: 30, Decel: 30, SpeedNoise: 0.02,
+		LateStart: 0.05, IncidentRate: 0.04, IncidentMax: 20, Recovery: 0.12,
+	}
+	profilePremium = trainProfile{
+		Class: "premium", MaxSpeed: 130, Accel: 18, Decel: 24, SpeedNoise: 0.03,
+		LateStart: 0.1, IncidentRate: 0.05, IncidentMax: 25, Recovery: 0.1,
+	}
+	profileSuperfast = trainProfile{
+		Class: "superfast", MaxSpeed: 110, Accel: 12, Decel: 20, SpeedNoise: 0.04,
+		LateStart: 0.2, IncidentRate: 0.08, IncidentMax: 30, Recovery: 0.08,
+	}
+	profileExpress = trainProfile{
+		Class: "express", MaxSpeed: 110, Accel: 10, Decel: 18, SpeedNoise: 0.05,
+		LateStart: 0.25, IncidentRate: 0.1, IncidentMax: 40, Recovery: 0.06,
+	}
+	profilePassenger = trainProfile{
+		Class: "passenger", MaxSpeed: 80, Accel: 12, Decel: 16, SpeedNoise: 0.06,
+		LateStart: 0.3, IncidentRate: 0.15, IncidentMax: 45, Recovery: 0.04,
+	}
+)
+
+// profileFor picks the profile for a trains.type value.
+func profileFor(trainType string) trainProfile {
+	t := strings.ToLower(trainType)
+	switch {
+	case strings.Contains(t, "vande"):
+		return profileVandeBharat
+	case strings.Contains(t, "rajdhani"), strings.Contains(t, "shatabdi"),
+		strings.Contains(t, "duronto"), strings.Contains(t, "tejas"),
+		strings.Contains(t, "gatimaan"):
+		return profilePremium
+	case strings.Contains(t, "superfast"), strings.Contains(t, "garib"),
+		strings.Contains(t, "humsafar"):
+		return profileSuperfast
+	case strings.Contains(t, "passenger"), strings.Contains(t, "memu"),
+		strings.Contains(t, "demu"), strings.Contains(t, "local"):
+		return profilePassenger
+	default:
+		return profileExpress
+	}
+}
+
+// profileForRoute is the class profile for a route, with the speed limit
+// raised where the train's published average speed says it runs faster.
+func profileForRoute(route *trainRoute) trainProfile {
+	p := profileFor(route.Type)
+	if limit := float64(route.AvgSpeed) * 1.5; limit > p.MaxSpeed {
+		p.MaxSpeed = math.Min(limit, profileVandeBharat.MaxSpeed)
+	}
+	return p
+}
+
+// sectionPlan is a trapezoidal speed profile over one section: accelerate
+// to cruise, hold it, brake to a stand. Speeds are km/min, times minutes.
+type sectionPlan struct {
+	dist     float64
+	cruise   float64
+	accelFor float64
+	decelFor float64
+	total    float64
+}
+
+// planSection fits a run of dist km into minutes using the profile's
+// acceleration and braking. If that would need more than the class's
+// maximum speed, the section takes longer instead.
+func planSection(dist, minutes float64, p trainProfile) sectionPlan {
+	a := p.Accel / 60 // km/min per min
+	b := p.Decel / 60
+	vmax := p.MaxSpeed / 60
+	k := 1/(2*a) + 1/(2*b)
+
+	plan := sectionPlan{dist: dist, total: minutes}
+	if dist <= 0 || minutes <= 0 {
+		return plan
+	}
+
+	// dist = v*T - k*v², solved for the smaller root.
+	disc := minutes*minutes - 4*k*dist
+	v := vmax
+	if disc >= 0 {
+		v = (minutes - math.Sqrt(disc)) / (2 * k)
+	}
+	if v > vmax || disc < 0 {
+		v = vmax
+		// Too short a section to reach vmax: a triangular profile.
+		if peak := math.Sqrt(dist / k); peak < v {
+			v = peak
+		}
+		plan.total = dist/v + k*v
+	}
+
+	plan.cruise = v
+	plan.accelFor = v / a
+	plan.decelFor = v / b
+	return plan
+}
+
+// at returns the distance covered and speed (km/h) t minutes into the
+// section.
+func (p sectionPlan) at(t float64) (float64, float64) {
+	if p.cruise == 0 || p.total <= 0 {
+		return p.dist * math.Min(1, math.Max(0, t/math.Max(p.total, 1))), 0
+	}
+	a := p.cruise / p.accelFor
+	b := p.cruise / p.decelFor
+	cruiseEnd := p.total - p.decelFor
+
+	switch {
+	case t <= 0:
+		return 0, 0
+	case t < p.accelFor:
+		return 0.5 * a * t * t, a * t * 60
+	case t < cruiseEnd:
+		return 0.5*a*p.accelFor*p.accelFor + p.cruise*(t-p.accelFor), p.cruise * 60
+	case t < p.total:
+		left := p.total - t
+		return p.dist - 0.5*b*left*left, b * left * 60
+	default:
+		return p.dist, 0
+	}
+}
@@ -0,0 +1,263 @@
+package mockgen
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rail-app/ingestion/internal/railtime"
+)
+
+// Scenario event types.
+const (
+	ScenarioFog           = "fog"
+	ScenarioSignalFailure = "signal_failure"
+	ScenarioCancellation  = "cancellation"
+	ScenarioPlatformSwap  = "platform_swap"
+)
+
+// defaultFogSpeedFactor is how fast trains run through fog when the
+// scenario does not say.
+const defaultFogSpeedFactor = 0.5
+
+// Scenario scripts disruptions for the mock generator. It is read from YAML
+// or JSON:
+//
+//	name: Morning fog and a signal failure
+//	events:
+//	  - type: fog
+//	    from: "+0h"
+//	    to: "+6h"
+//	    states: [Uttar Pradesh]
+//	    speed_factor: 0.4
+//	  - type: signal_failure
+//	    from: 2026-10-20T02:00:00+05:30
+//	    to: 2026-10-20T03:30:00+05:30
+//	    section: [CNB, PRYJ]
+//	  - type: cancellation
+//	    train: "12301"
+//	    date: 2026-10-20
+//	  - type: platform_swap
+//	    station: NDLS
+//	    train: "12951"
+//	    platform: "7"
+//
+// Times are RFC 3339 or a "+duration" offset from the simulation start.
+// Omitted times leave the window open at that end, except that a signal
+// failure must say when the section reopens.
+type Scenario struct {
+	Name   string          `yaml:"name"`
+	Events []ScenarioEvent `yaml:"events"`
+}
+
+type ScenarioEvent struct {
+	Type string `yaml:"type"`
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+
+	// fog
+	Stations    []string `yaml:"stations"`
+	States      []string `yaml:"states"`
+	SpeedFactor float64  `yaml:"speed_factor"`
+
+	// signal_failure: two adjacent stops, blocked in both directions
+	Section []string `yaml:"section"`
+
+	// cancellation, platform_swap
+	Train string `yaml:"train"`
+	Date  string `yaml:"date"` // run start date, YYYY-MM-DD
+
+	// platform_swap
+	Station  string `yaml:"station"`
+	Platform string `yaml:"platform"`
+
+	from, to time.Time
+	date     time.Time
+	stations map[string]bool
+	states   map[string]bool
+}
+
+// LoadScenario reads a scenario file and resolves its times against start.
+func LoadScenario(path string, start time.Time) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+	for i := range sc.Events {
+		if err := sc.Events[i].resolve(start); err != nil {
+			return nil, fmt.Errorf("scenario %s event %d: %w", path, i+1, err)
+		}
+	}
+	return &sc, nil
+}
+
+func (e *ScenarioEvent) resolve(start time.Time) error {
+	var err error
+	if e.from, err = scenarioTime(e.From, start); err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	if e.to, err = scenarioTime(e.To, start); err != nil {
+		return fmt.Errorf("to: %w", err)
+	}
+	if !e.from.IsZero() && !e.to.IsZero() && !e.to.After(e.from) {
+		return fmt.Errorf("to %s is not after from %s", e.To, e.From)
+	}
+
+	e.stations = upperSet(e.Stations)
+	e.states = make(map[string]bool, len(e.States))
+	for _, st := range e.States {
+		e.states[strings.ToLower(strings.TrimSpace(st))] = true
+	}
+	e.Station = strings.ToUpper(e.Station)
+	for i := range e.Section {
+		e.Section[i] = strings.ToUpper(e.Section[i])
+	}
+
+	switch e.Type {
+	case ScenarioFog:
+		if len(e.stations) == 0 && len(e.states) == 0 {
+			return fmt.Errorf("fog needs stations or states")
+		}
+		if e.SpeedFactor == 0 {
+			e.SpeedFactor = defaultFogSpeedFactor
+		}
+		if e.SpeedFactor < 0 || e.SpeedFactor > 1 {
+			return fmt.Errorf("speed_factor %g outside (0, 1]", e.SpeedFactor)
+		}
+	case ScenarioSignalFailure:
+		if len(e.Section) != 2 {
+			return fmt.Errorf("signal_failure needs a section of two stations")
+		}
+		if e.to.IsZero() {
+			return fmt.Errorf("signal_failure needs a to time for the section to reopen")
+		}
+	case ScenarioCancellation:
+		if e.Train == "" {
+			return fmt.Errorf("cancellation needs a train")
+		}
+	case ScenarioPlatformSwap:
+		if e.Station == "" || e.Platform == "" {
+			return fmt.Errorf("platform_swap needs a station and a platform")
+		}
+	default:
+		return fmt.Errorf("unknown event type %q", e.Type)
+	}
+
+	if e.Date != "" {
+		if e.date, err = time.ParseInLocation("2006-01-02", e.Date, railtime.IST); err != nil {
+			return fmt.Errorf("date: %w", err)
+		}
+	}
+	return nil
+}
+
+func scenarioTime(s string, start time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if strings.HasPrefix(s, "+") {
+		d, err := time.ParseDuration(s[1:])
+		if err != nil {
+			return time.Time{}, err
+		}
+		return start.Add(d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func upperSet(codes []string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, c := range codes {
+		set[strings.ToUpper(strings.TrimSpace(c))] = true
+	}
+	return set
+}
+
+// activeAt reports whether t falls in the event's window.
+func (e *ScenarioEvent) activeAt(t time.Time) bool {
+	return (e.from.IsZero() || !t.Before(e.from)) && (e.to.IsZero() || t.Before(e.to))
+}
+
+func (e *ScenarioEvent) covers(stop routeStop) bool {
+	return e.stations[stop.StationCode] || e.states[strings.ToLower(stop.State)]
+}
+
+// fogFactor is the speed factor for a section entered at t, 1 when clear.
+func (sc *Scenario) fogFactor(a, b routeStop, t time.Time) float64 {
+	factor := 1.0
+	if sc == nil {
+		return factor
+	}
+	for i := range sc.Events {
+		e := &sc.Events[i]
+		if e.Type == ScenarioFog && e.activeAt(t) && (e.covers(a) || e.covers(b)) && e.SpeedFactor < factor {
+			factor = e.SpeedFactor
+		}
+	}
+	return factor
+}
+
+// blockedUntil returns when the section a–b reopens if a signal failure
+// blocks it at t.
+func (sc *Scenario) blockedUntil(a, b string, t time.Time) (time.Time, bool) {
+	if sc == nil {
+		return time.Time{}, false
+	}
+	for i := range sc.Events {
+		e := &sc.Events[i]
+		if e.Type != ScenarioSignalFailure || !e.activeAt(t) {
+			continue
+		}
+		if (e.Section[0] == a && e.Section[1] == b) || (e.Section[0] == b && e.Section[1] == a) {
+			return e.to, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// cancelled reports whether the run of train leaving its source at dep on
+// startDate is cancelled.
+func (sc *Scenario) cancelled(train string, startDate, dep time.Time) bool {
+	if sc == nil {
+		return false
+	}
+	for i := range sc.Events {
+		e := &sc.Events[i]
+		if e.Type != ScenarioCancellation || e.Train != train {
+			continue
+		}
+		if !e.date.IsZero() {
+			if e.date.Equal(startDate) {
+				return true
+			}
+			continue
+		}
+		if e.activeAt(dep) {
+			return true
+		}
+	}
+	return false
+}
+
+// platformSwap returns the platform a scenario moves train to at station at
+// time t.
+func (sc *Scenario) platformSwap(train, station string, t time.Time) (string, bool) {
+	if sc == nil {
+		return "", false
+	}
+	for i := range sc.Events {
+		e := &sc.Events[i]
+		if e.Type == ScenarioPlatformSwap && e.Station == station &&
+			(e.Train == "" || e.Train == train) && e.activeAt(t) {
+			return e.Platform, true
+		}
+	}
+	return "", false
+}
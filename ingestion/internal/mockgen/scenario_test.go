@@ -0,0 +1,136 @@
+package mockgen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/railtime"
+)
+
+func writeScenario(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadScenario(t *testing.T) {
+	start := time.Date(2026, 12, 20, 22, 0, 0, 0, railtime.IST)
+	path := writeScenario(t, `
+name: Test
+events:
+  - type: fog
+    from: "+0h"
+    to: "+2h"
+    states: [Uttar Pradesh]
+    speed_factor: 0.4
+  - type: fog
+    stations: [ndls]
+  - type: signal_failure
+    from: "+1h"
+    to: "+1h30m"
+    section: [cnb, PRYJ]
+  - type: cancellation
+    train: "12002"
+    date: 2026-12-21
+  - type: platform_swap
+    from: 2026-12-21T04:00:00+05:30
+    station: ndls
+    train: "12301"
+    platform: "7"
+`)
+	sc, err := LoadScenario(path, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up := routeStop{StationCode: "CNB", State: "Uttar Pradesh"}
+	ndls := routeStop{StationCode: "NDLS", State: "Delhi"}
+	bihar := routeStop{StationCode: "PNBE", State: "Bihar"}
+	if got := sc.fogFactor(up, bihar, start.Add(time.Hour)); got != 0.4 {
+		t.Errorf("fog in Uttar Pradesh during the window = %g, want 0.4", got)
+	}
+	if got := sc.fogFactor(up, bihar, start.Add(3*time.Hour)); got != 1 {
+		t.Errorf("fog in Uttar Pradesh after the window = %g, want 1", got)
+	}
+	if got := sc.fogFactor(ndls, bihar, start.Add(48*time.Hour)); got != defaultFogSpeedFactor {
+		t.Errorf("open-ended fog at NDLS = %g, want the default %g", got, defaultFogSpeedFactor)
+	}
+
+	if until, blocked := sc.blockedUntil("PRYJ", "CNB", start.Add(75*time.Minute)); !blocked || !until.Equal(start.Add(90*time.Minute)) {
+		t.Errorf("blockedUntil during the failure = %s, %t, want %s", until, blocked, start.Add(90*time.Minute))
+	}
+	if _, blocked := sc.blockedUntil("CNB", "PRYJ", start.Add(2*time.Hour)); blocked {
+		t.Error("section still blocked after the failure")
+	}
+
+	runDate := time.Date(2026, 12, 21, 0, 0, 0, 0, railtime.IST)
+	if !sc.cancelled("12002", runDate, runDate.Add(6*time.Hour)) {
+		t.Error("run of 12002 on the cancelled date not cancelled")
+	}
+	if sc.cancelled("12002", runDate.AddDate(0, 0, 1), runDate.Add(30*time.Hour)) {
+		t.Error("run of 12002 on the next day cancelled")
+	}
+
+	if _, ok := sc.platformSwap("12301", "NDLS", runDate.Add(3*time.Hour)); ok {
+		t.Error("platform swapped before the swap starts")
+	}
+	if pf, ok := sc.platformSwap("12301", "NDLS", runDate.Add(5*time.Hour)); !ok || pf != "7" {
+		t.Errorf("platformSwap = %q, %t, want 7", pf, ok)
+	}
+}
+
+func TestLoadScenarioRejects(t *testing.T) {
+	tests := []struct {
+		event string
+		want  string
+	}{
+		{"type: signal_failure\n    from: \"+1h\"\n    section: [CNB, PRYJ]", "to time"},
+		{"type: signal_failure\n    to: \"+1h\"\n    section: [CNB]", "section"},
+		{"type: fog\n    from: \"+2h\"\n    to: \"+1h\"\n    states: [Delhi]", "not after"},
+		{"type: fog\n    speed_factor: 0.5", "stations or states"},
+		{"type: fog\n    states: [Delhi]\n    speed_factor: 1.5", "speed_factor"},
+		{"type: cancellation\n    date: 2026-12-21", "needs a train"},
+		{"type: platform_swap\n    station: NDLS", "platform"},
+		{"type: landslide", "unknown event type"},
+	}
+	for _, tt := range tests {
+		path := writeScenario(t, "events:\n  - "+tt.event+"\n")
+		_, err := LoadScenario(path, time.Now())
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: err = %v, want one mentioning %q", tt.event, err, tt.want)
+		}
+	}
+}
+
+// A replica taking over as leader hours into the simulation must resolve
+// the scenario's offsets from the same start as the first one did.
+func TestScenarioAnchoredToMockStartTime(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.MockStartTime = "2026-12-20T22:00:00+05:30"
+	m := New(cfg, nil)
+	m.SetClock(NewVirtualClock(time.Date(2026, 12, 21, 3, 0, 0, 0, railtime.IST)))
+
+	want := time.Date(2026, 12, 20, 22, 0, 0, 0, railtime.IST)
+	if got := m.simulationStart(); !got.Equal(want) {
+		t.Errorf("simulationStart = %s, want MockStartTime %s", got, want)
+	}
+}
+
+func TestScenarioAnchoredToFirstStart(t *testing.T) {
+	m := New(config.Defaults(), nil)
+	clock := NewVirtualClock(time.Date(2026, 12, 20, 22, 0, 0, 0, railtime.IST))
+	m.SetClock(clock)
+
+	first := m.simulationStart()
+	clock.Advance(5 * time.Hour)
+	if got := m.simulationStart(); !got.Equal(first) {
+		t.Errorf("simulationStart moved from %s to %s on a later Start", first, got)
+	}
+}
@@ -0,0 +1,483 @@
+package mockgen
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/publisher"
+)
+
+const (
+	// minHalt is the shortest stop a late train makes at an intermediate
+	// station while recovering time.
+	minHalt = time.Minute
+	// delayReportStep is how much a delay must grow before another
+	// DelayEvent is published for the run.
+	delayReportStep = 5
+)
+
+var delayCauses = []string{
+	"Fog/Low Visibility",
+	"Signal Failure",
+	"Track Maintenance",
+	"Congestion",
+	"Waiting for Crossing",
+	"Technical Issue",
+	"Caution Order",
+}
+
+// schedStop is a stop's scheduled arrival and departure on a particular
+// run. The source has no arrival and the destination no departure.
+type schedStop struct {
+	Arr  time.Time
+	Dep  time.Time
+	Halt time.Duration
+}
+
+// trainRun is one run of a train from its source to its destination,
+// simulated event by event. Between events the train is either halted at
+// stop or running from stop to stop+1.
+type trainRun struct {
+	route     *trainRoute
+	profile   trainProfile
+	startDate time.Time // IST midnight of the day the run leaves its source
+	sched     []schedStop
+	platforms []string
+
+	stop       int
+	running    bool
+	arrivedAt  time.Time // actual arrival at stop
+	departAt   time.Time // actual (or planned, while halted) departure from stop
+	arriveNext time.Time // planned arrival at stop+1 while running
+	plan       sectionPlan
+	cause      string // why the current section is running late, if it is
+	reported   int    // delay last published as a DelayEvent
+	done       bool
+}
+
+func newTrainRun(route *trainRoute, startDate time.Time) *trainRun {
+	return &trainRun{
+		route:     route,
+		profile:   profileForRoute(route),
+		startDate: startDate,
+		sched:     buildSchedule(route.Stops, startDate),
+		platforms: make([]string, len(route.Stops)),
+	}
+}
+
+// key identifies the run across ticks.
+func (r *trainRun) key() string {
+	return r.route.TrainNumber + "/" + r.startDate.Format("2006-01-02")
+}
+
+// buildSchedule places every stop's times on the run's timeline. Halts
+// default to the gap between arrival and departure, and times that would go
+// backwards (a day_number off by one in the data) roll over to the next day.
+func buildSchedule(stops []routeStop, startDate time.Time) []schedStop {
+	sched := make([]schedStop, len(stops))
+	var prev time.Time
+	place := func(hhmm string, day int) time.Time {
+		t, ok := clockOn(startDate, hhmm, day)
+		if !ok {
+			return time.Time{}
+		}
+		for !prev.IsZero() && t.Before(prev) {
+			t = t.AddDate(0, 0, 1)
+		}
+		prev = t
+		return t
+	}
+
+	for i, stop := range stops {
+		s := &sched[i]
+		s.Arr = place(stop.ArrivalTime, stop.DayNumber)
+		s.Dep = place(stop.DepartureTime, stop.DayNumber)
+		s.Halt = time.Duration(stop.HaltMinutes) * time.Minute
+
+		// Fill gaps so every stop has both times.
+		if s.Arr.IsZero() && !s.Dep.IsZero() {
+			s.Arr = s.Dep.Add(-s.Halt)
+		}
+		if s.Dep.IsZero() && !s.Arr.IsZero() {
+			s.Dep = s.Arr.Add(s.Halt)
+		}
+		if s.Arr.IsZero() && s.Dep.IsZero() {
+			s.Arr, s.Dep = prev, prev
+		}
+		if s.Halt == 0 {
+			s.Halt = s.Dep.Sub(s.Arr)
+		}
+	}
+	return sched
+}
+
+// clockOn returns "HH:MM[:SS]" on the dayNumber-th day of a run.
+func clockOn(startDate time.Time, hhmm string, dayNumber int) (time.Time, bool) {
+	if len(hhmm) < 5 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("15:04", hhmm[:5])
+	if err != nil {
+		return time.Time{}, false
+	}
+	if dayNumber < 1 {
+		dayNumber = 1
+	}
+	return startDate.AddDate(0, 0, dayNumber-1).
+		Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), true
+}
+
+// delayAt is how late the run is at t relative to the scheduled time st.
+func delayAt(t, st time.Time) int {
+	d := int(math.Round(t.Sub(st).Minutes()))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// currentDelay is the run's delay as of its last event.
+func (r *trainRun) currentDelay() int {
+	if r.running {
+		return delayAt(r.departAt, r.sched[r.stop].Dep)
+	}
+	if r.stop == 0 {
+		return delayAt(r.departAt, r.sched[0].Dep)
+	}
+	return delayAt(r.arrivedAt, r.sched[r.stop].Arr)
+}
+
+// begin sets the run up at its source, possibly with a late start.
+func (m *MockGenerator) begin(r *trainRun) {
+	late := 0
+	if m.rng.Float64() < r.profile.LateStart {
+		late = 1 + m.rng.Intn(20)
+		r.cause = "Late Departure"
+	}
+	r.departAt = r.sched[0].Dep.Add(time.Duration(late) * time.Minute)
+}
+
+// nextEvent is when the run's next departure or arrival falls due.
+func (r *trainRun) nextEvent() time.Time {
+	if r.running {
+		return r.arriveNext
+	}
+	return r.departAt
+}
+
+// advanceAll plays the events of runs up to now in time order, so trains
+// sharing a section see each other as they would on the line. When publish
+// is false it only updates state, which is how runs already under way at
+// startup catch up.
+func (m *MockGenerator) advanceAll(ctx context.Context, runs []*trainRun, now time.Time, publish bool) {
+	if m.blocks == nil {
+		// Without block working runs are independent; skip the ordering.
+		for _, r := range runs {
+			for !r.done && !r.nextEvent().After(now) && ctx.Err() == nil {
+				m.step(ctx, r, publish)
+			}
+		}
+		return
+	}
+	for ctx.Err() == nil {
+		var next *trainRun
+		for _, r := range runs {
+			if r.done || r.nextEvent().After(now) {
+				continue
+			}
+			if next == nil || r.nextEvent().Before(next.nextEvent()) {
+				next = r
+			}
+		}
+		if next == nil {
+			return
+		}
+		m.step(ctx, next, publish)
+	}
+	m.blocks.prune(now.Add(-time.Hour))
+}
+
+// step plays the run's next event: a departure, which may instead be held
+// while the section ahead is blocked, or an arrival.
+func (m *MockGenerator) step(ctx context.Context, r *trainRun, publish bool) {
+	last := len(r.sched) - 1
+	if !r.running {
+		next := r.route.Stops[r.stop+1].StationCode
+		if until, blocked := m.scenario.blockedUntil(r.route.Stops[r.stop].StationCode, next, r.departAt); blocked {
+			r.cause = "Signal Failure"
+			m.hold(ctx, r, until, publish)
+			return
+		}
+		if until, ahead, held := m.blocks.heldUntil(r, r.departAt); held {
+			r.cause = holdCause(r, ahead)
+			m.hold(ctx, r, until, publish)
+			return
+		}
+
+		r.running = true
+		if publish {
+			if r.stop == 0 {
+				m.publishRunEvent(ctx, r, "run_started", 0, r.departAt, r.sched[0].Dep)
+			}
+			m.publishPlatform(ctx, r, r.stop, "departure", r.departAt)
+			m.maybeReportDelay(ctx, r, r.stop, r.departAt, r.sched[r.stop].Dep, r.departAt)
+		}
+		r.arriveNext = r.departAt.Add(m.sectionTime(r))
+		m.blocks.enter(r, r.departAt, r.arriveNext)
+		return
+	}
+
+	r.stop++
+	r.running = false
+	r.arrivedAt = r.arriveNext
+
+	if publish {
+		m.publishPlatform(ctx, r, r.stop, "arrival", r.arrivedAt)
+		m.maybeReportDelay(ctx, r, r.stop, r.arrivedAt, r.sched[r.stop].Arr, r.arrivedAt)
+	}
+	if r.stop == last {
+		r.done = true
+		if publish {
+			m.publishRunEvent(ctx, r, "run_ended", last, r.arrivedAt, r.sched[last].Arr)
+		}
+		return
+	}
+	r.departAt = m.departureFrom(r)
+}
+
+// hold keeps the run at its stop until until, reporting the delay it will
+// leave with.
+func (m *MockGenerator) hold(ctx context.Context, r *trainRun, until time.Time, publish bool) {
+	if publish {
+		m.maybeReportDelay(ctx, r, r.stop, until, r.sched[r.stop].Dep, r.departAt)
+	}
+	r.departAt = until
+}
+
+// sectionTime is how long the run takes from stop to stop+1 and plans its
+// speed over the section: the scheduled running time with some noise, an
+// occasional incident, and some recovery when the train is late, all scaled
+// to the train's class. Where the timetable has no running time the train's
+// average speed stands in. A plan that would exceed the class's speed limit
+// takes longer.
+func (m *MockGenerator) sectionTime(r *trainRun) time.Duration {
+	p := r.profile
+	dist := sectionKm(r.route.Stops[r.stop], r.route.Stops[r.stop+1])
+	sched := r.sched[r.stop+1].Arr.Sub(r.sched[r.stop].Dep)
+	if sched <= 0 && r.route.AvgSpeed > 0 && dist > 0 {
+		sched = time.Duration(dist / float64(r.route.AvgSpeed) * float64(time.Hour))
+	}
+	if sched <= 0 {
+		sched = time.Minute
+	}
+	minutes := sched.Minutes() * (1 + m.rng.NormFloat64()*p.SpeedNoise)
+
+	if f := m.scenario.fogFactor(r.route.Stops[r.stop], r.route.Stops[r.stop+1], r.departAt); f < 1 {
+		minutes /= f
+		r.cause = "Fog/Low Visibility"
+	}
+
+	if m.rng.Float64() < p.IncidentRate {
+		minutes += float64(5 + m.rng.Intn(p.IncidentMax-4))
+		r.cause = delayCauses[m.rng.Intn(len(delayCauses))]
+	} else if late := float64(r.currentDelay()); late > 0 {
+		// Running time allowances let a late train claw back part of the
+		// section; premium trains get the clearer path and more of it.
+		minutes -= math.Min(late, sched.Minutes()*p.Recovery) * m.rng.Float64()
+	}
+
+	if minutes < 1 {
+		minutes = 1
+	}
+	r.plan = planSection(dist, minutes, p)
+	return time.Duration(r.plan.total * float64(time.Minute)).Round(time.Second)
+}
+
+// departureFrom is when the run leaves the stop it has just reached: never
+// before the timetable, and after at least the halt, shortened for a late
+// train.
+func (m *MockGenerator) departureFrom(r *trainRun) time.Time {
+	s := r.sched[r.stop]
+	halt := s.Halt
+	if r.arrivedAt.After(s.Arr) && halt > 2*minHalt {
+		halt /= 2
+	}
+	if halt < minHalt {
+		halt = minHalt
+	}
+	dep := r.arrivedAt.Add(halt)
+	if dep.Before(s.Dep) {
+		dep = s.Dep
+	}
+	return dep
+}
+
+// maybeReportDelay publishes, as of at, a DelayEvent when the run's delay
+// at actual has grown by delayReportStep since the last one, and notes
+// recovery silently.
+func (m *MockGenerator) maybeReportDelay(ctx context.Context, r *trainRun, idx int, actual, scheduled, at time.Time) {
+	delay := delayAt(actual, scheduled)
+	if delay < r.reported {
+		r.reported = delay
+		return
+	}
+	if delay-r.reported < delayReportStep {
+		return
+	}
+	r.reported = delay
+
+	cause := r.cause
+	if cause == "" {
+		cause = "Congestion"
+	}
+	ev := publisher.DelayEvent{
+		TrainNumber:   r.route.TrainNumber,
+		StationCode:   r.route.Stops[idx].StationCode,
+		ScheduledTime: scheduled.Format(time.RFC3339),
+		ActualTime:    actual.Format(time.RFC3339),
+		DelayMinutes:  delay,
+		Cause:         cause,
+		RunStartDate:  r.startDate.Format("2006-01-02"),
+		Timestamp:     at.Format(time.RFC3339),
+	}
+	if err := m.publish(func() error { return m.pub.PublishDelayEvent(ctx, ev) }); err != nil {
+		log.Printf("Failed to publish delay event for %s: %v", r.route.TrainNumber, err)
+	}
+	r.cause = ""
+}
+
+// publishRunEvent marks the run entering or leaving service at stop idx.
+func (m *MockGenerator) publishRunEvent(ctx context.Context, r *trainRun, eventType string, idx int, actual, scheduled time.Time) {
+	ev := publisher.RunEvent{
+		TrainNumber:   r.route.TrainNumber,
+		EventType:     eventType,
+		StationCode:   r.route.Stops[idx].StationCode,
+		ScheduledTime: scheduled.Format(time.RFC3339),
+		ActualTime:    actual.Format(time.RFC3339),
+		DelayMinutes:  delayAt(actual, scheduled),
+		RunStartDate:  r.startDate.Format("2006-01-02"),
+		Timestamp:     actual.Format(time.RFC3339),
+	}
+	if err := m.publish(func() error { return m.pub.PublishRunEvent(ctx, ev) }); err != nil {
+		log.Printf("Failed to publish %s for %s: %v", eventType, r.route.TrainNumber, err)
+	} else if m.load == nil {
+		log.Printf("%s (%s) run of %s: %s at %s", r.route.TrainNumber, r.route.TrainName,
+			ev.RunStartDate, eventType, ev.StationCode)
+	}
+}
+
+func (m *MockGenerator) publishPlatform(ctx context.Context, r *trainRun, idx int, eventType string, at time.Time) {
+	if p, ok := m.scenario.platformSwap(r.route.TrainNumber, r.route.Stops[idx].StationCode, at); ok && r.platforms[idx] == "" {
+		r.platforms[idx] = p
+	}
+	if r.platforms[idx] == "" {
+		r.platforms[idx] = r.route.Stops[idx].Platform
+		if r.platforms[idx] == "" {
+			r.platforms[idx] = fmt.Sprintf("%d", 1+m.rng.Intn(10))
+		}
+	}
+	ev := publisher.PlatformChange{
+		StationCode:    r.route.Stops[idx].StationCode,
+		PlatformNumber: r.platforms[idx],
+		TrainNumber:    r.route.TrainNumber,
+		EventType:      eventType,
+		RunStartDate:   r.startDate.Format("2006-01-02"),
+		Timestamp:      at.Format(time.RFC3339),
+	}
+	if err := m.publish(func() error { return m.pub.PublishPlatformChange(ctx, ev) }); err != nil {
+		log.Printf("Failed to publish platform event: %v", err)
+	}
+}
+
+// announcePlatformSwap publishes a "change" PlatformChange when a scenario
+// moves the run to a different platform at its next stop.
+func (m *MockGenerator) announcePlatformSwap(ctx context.Context, r *trainRun, now time.Time) {
+	next := r.stop + 1
+	if r.done || next >= len(r.route.Stops) {
+		return
+	}
+	stop := r.route.Stops[next]
+	p, ok := m.scenario.platformSwap(r.route.TrainNumber, stop.StationCode, now)
+	if !ok || r.platforms[next] == p || (r.platforms[next] == "" && stop.Platform == p) {
+		return
+	}
+	r.platforms[next] = p
+
+	ev := publisher.PlatformChange{
+		StationCode:    stop.StationCode,
+		PlatformNumber: p,
+		TrainNumber:    r.route.TrainNumber,
+		EventType:      "change",
+		RunStartDate:   r.startDate.Format("2006-01-02"),
+		Timestamp:      now.Format(time.RFC3339),
+	}
+	if err := m.publish(func() error { return m.pub.PublishPlatformChange(ctx, ev) }); err != nil {
+		log.Printf("Failed to publish platform change: %v", err)
+	}
+}
+
+// position is where the run is at now, which must not be past its next
+// event.
+func (r *trainRun) position(now time.Time) publisher.TrainPosition {
+	here := r.route.Stops[r.stop]
+	pos := publisher.TrainPosition{
+		TrainNumber:    r.route.TrainNumber,
+		Latitude:       here.Latitude,
+		Longitude:      here.Longitude,
+		DelayMinutes:   r.currentDelay(),
+		CurrentStation: here.StationCode,
+		RunStartDate:   r.startDate.Format("2006-01-02"),
+		Timestamp:      now.Format(time.RFC3339),
+	}
+	if r.stop+1 >= len(r.route.Stops) {
+		return pos
+	}
+
+	next := r.route.Stops[r.stop+1]
+	pos.NextStation = next.StationCode
+	if !r.running {
+		eta := r.departAt.Add(r.sched[r.stop+1].Arr.Sub(r.sched[r.stop].Dep))
+		pos.ETANext = eta.Format(time.RFC3339)
+		return pos
+	}
+
+	// Follow the section's speed plan: slow away from the platform, cruise,
+	// brake into the next stop.
+	elapsed := now.Sub(r.departAt).Minutes()
+	covered, speed := r.plan.at(elapsed)
+	progress := 1.0
+	if r.plan.dist > 0 {
+		progress = covered / r.plan.dist
+	} else if total := r.arriveNext.Sub(r.departAt).Minutes(); total > 0 {
+		progress = elapsed / total
+	}
+	progress = math.Max(0, math.Min(1, progress))
+
+	pos.Latitude = here.Latitude + (next.Latitude-here.Latitude)*progress
+	pos.Longitude = here.Longitude + (next.Longitude-here.Longitude)*progress
+	pos.SpeedKmph = int(math.Round(speed))
+	pos.ETANext = r.arriveNext.Format(time.RFC3339)
+	return pos
+}
+
+// sectionKm is the distance between consecutive stops, from the timetable
+// or, where that is missing, as the crow flies.
+func sectionKm(a, b routeStop) float64 {
+	if d := b.DistFromSource - a.DistFromSource; d > 0 {
+		return float64(d)
+	}
+	return haversineKm(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+}
+
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadiusKm = 6371
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,44 @@
+package publisher
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/metrics"
+)
+
+// Sinks, as labelled in metrics.
+const (
+	sinkParseable = "parseable"
+	sinkValkey    = "valkey"
+)
+
+var (
+	eventsPublished = metrics.NewCounterVec("ingestion_events_published_total",
+		"Events delivered, by Parseable stream or Valkey channel family, and sink.", "stream", "sink")
+	publishErrors = metrics.NewCounterVec("ingestion_publish_errors_total",
+		"Deliveries that failed, by stream and sink.", "stream", "sink")
+	publishFenced = metrics.NewCounterVec("ingestion_publish_fenced_total",
+		"Events dropped because this replica was not the leader, by sink.", "sink")
+	publishDuration = metrics.NewHistogramVec("ingestion_publish_duration_seconds",
+		"Time taken by one delivery to a sink.", metrics.DurationBuckets, "sink")
+)
+
+// observePublish records one delivery of n events that started at start.
+func observePublish(stream, sink string, n int, start time.Time, err error) {
+	publishDuration.With(sink).ObserveSince(start)
+	if err != nil {
+		publishErrors.With(stream, sink).Inc()
+		return
+	}
+	eventsPublished.With(stream, sink).Add(float64(n))
+}
+
+// channelFamily drops the per-train or per-station suffix from a Valkey
+// channel, so train:live:12301 is counted as train:live.
+func channelFamily(channel string) string {
+	if i := strings.LastIndexByte(channel, ':'); i > 0 {
+		return channel[:i]
+	}
+	return channel
+}
@@ -5,9 +5,11 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -24,6 +26,8 @@ type TrainPosition struct {
 	CurrentStation string  `json:"current_station"`
 	NextStation    string  `json:"next_station"`
 	ETANext        string  `json:"eta_next"`
+	RunStartDate   string  `json:"run_start_date,omitempty"`
+	Diverted       bool    `json:"diverted,omitempty"` // at a station off the route
 	Timestamp      string  `json:"timestamp"`
 }
 
@@ -32,6 +36,7 @@ type PlatformChange struct {
 	PlatformNumber string `json:"platform_number"`
 	TrainNumber    string `json:"train_number"`
 	EventType      string `json:"event_type"`
+	RunStartDate   string `json:"run_start_date,omitempty"`
 	Timestamp      string `json:"timestamp"`
 }
 
@@ -42,6 +47,21 @@ type DelayEvent struct {
 	ActualTime    string `json:"actual_time"`
 	DelayMinutes  int    `json:"delay_minutes"`
 	Cause         string `json:"cause"`
+	RunStartDate  string `json:"run_start_date,omitempty"`
+	Timestamp     string `json:"timestamp"`
+}
+
+// RunEvent marks a run of a train entering or leaving service: EventType
+// is "run_started" when it leaves its source and "run_ended" when it reaches
+// its destination.
+type RunEvent struct {
+	TrainNumber   string `json:"train_number"`
+	EventType     string `json:"event_type"`
+	StationCode   string `json:"station_code"`
+	ScheduledTime string `json:"scheduled_time"`
+	ActualTime    string `json:"actual_time"`
+	DelayMinutes  int    `json:"delay_minutes"`
+	RunStartDate  string `json:"run_start_date"`
 	Timestamp     string `json:"timestamp"`
 }
 
@@ -54,13 +74,128 @@ type PnrStatusChange struct {
 	Timestamp string `json:"timestamp"`
 }
 
+type ServiceDisruption struct {
+	EventType        string   `json:"event_type"`
+	TrainNumber      string   `json:"train_number"`
+	DisruptionType   string   `json:"disruption_type"`
+	AffectedStations []string `json:"affected_stations"`
+	NewDepartureTime string   `json:"new_departure_time,omitempty"`
+	Details          string   `json:"details"`
+	AffectedJourneys int      `json:"affected_journeys"`
+	Timestamp        string   `json:"timestamp"`
+}
+
+type JourneyDisruption struct {
+	UserID      string            `json:"user_id"`
+	JourneyID   string            `json:"journey_id"`
+	PNR         string            `json:"pnr,omitempty"`
+	TrainNumber string            `json:"train_number"`
+	Disruption  ServiceDisruption `json:"disruption"`
+}
+
+type StationBoardTrain struct {
+	TrainNumber        string `json:"train_number"`
+	TrainName          string `json:"train_name"`
+	ScheduledArrival   string `json:"scheduled_arrival"`
+	ExpectedArrival    string `json:"expected_arrival"`
+	ScheduledDeparture string `json:"scheduled_departure"`
+	ExpectedDeparture  string `json:"expected_departure"`
+	DelayMinutes       int    `json:"delay_minutes"`
+	Platform           string `json:"platform"`
+}
+
+type StationBoard struct {
+	EventType   string              `json:"event_type"`
+	StationCode string              `json:"station_code"`
+	WindowHours int                 `json:"window_hours"`
+	Trains      []StationBoardTrain `json:"trains"`
+	Timestamp   string              `json:"timestamp"`
+}
+
+type ExpectedPlatform struct {
+	EventType         string `json:"event_type"`
+	StationCode       string `json:"station_code"`
+	TrainNumber       string `json:"train_number"`
+	PlatformNumber    string `json:"platform_number"`
+	ExpectedArrival   string `json:"expected_arrival"`
+	ExpectedDeparture string `json:"expected_departure"`
+	DelayMinutes      int    `json:"delay_minutes"`
+	Timestamp         string `json:"timestamp"`
+}
+
+// QuarantinedEvent is a scraped running event that failed validation.
+type QuarantinedEvent struct {
+	TrainNumber  string `json:"train_number"`
+	Source       string `json:"source"`
+	Reason       string `json:"reason"`
+	Detail       string `json:"detail"`
+	EventType    string `json:"event_type"`
+	StationCode  string `json:"station_code"`
+	StationName  string `json:"station_name"`
+	EventTime    string `json:"event_time"`
+	DelayMinutes int    `json:"delay_minutes"`
+	Platform     string `json:"platform"`
+	Timestamp    string `json:"timestamp"`
+}
+
+// RefreshRequest is pushed by the backend onto the refresh work queue.
+type RefreshRequest struct {
+	TrainNumber   string `json:"train_number"`
+	CorrelationID string `json:"correlation_id"`
+	RequestedAt   string `json:"requested_at"`
+}
+
+type RefreshReply struct {
+	CorrelationID string `json:"correlation_id"`
+	TrainNumber   string `json:"train_number"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	Timestamp     string `json:"timestamp"`
+}
+
+// refreshReplyTTL keeps replies readable for callers that subscribe late.
+const refreshReplyTTL = 2 * time.Minute
+
 type Publisher struct {
-	cfg        *config.Config
+	cfg        atomic.Pointer[config.Config]
 	httpClient *http.Client
 	authHeader string
 	rdb        *redis.Client
+
+	// fencer, when set, limits publishing to the elected leader.
+	fencer Fencer
+}
+
+// Fencer guards publishing when replicas elect a leader. Fence returns the
+// Valkey key holding the newest fencing token and this replica's token; ok
+// is false when this replica must not publish.
+type Fencer interface {
+	Fence() (key string, token int64, ok bool)
 }
 
+// errFenced means the event was dropped because this replica is not, or
+// is no longer, the leader.
+var errFenced = errors.New("not the leader")
+
+// fencedPublish publishes only while the fencing key still holds the
+// publisher's token, so a deposed leader cannot publish after a new one
+// has taken over.
+var fencedPublish = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PUBLISH', ARGV[2], ARGV[3])
+end
+return -1
+`)
+
+// fencedSet is fencedPublish for a key written with a TTL.
+var fencedSet = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+	return 1
+end
+return -1
+`)
+
 func New(cfg *config.Config) (*Publisher, error) {
 	auth := base64.StdEncoding.EncodeToString(
 		[]byte(fmt.Sprintf("%s:%s", cfg.ParseableUser, cfg.ParseablePassword)),
@@ -79,14 +214,113 @@ func New(cfg *config.Config) (*Publisher, error) {
 		log.Println("Connected to Valkey")
 	}
 
-	return &Publisher{
-		cfg: cfg,
+	p := &Publisher{
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
 		authHeader: "Basic " + auth,
 		rdb:        rdb,
-	}, nil
+	}
+	p.cfg.Store(cfg)
+	return p, nil
+}
+
+// Close releases the publisher's connections. Events are sent as they are
+// published, so there is nothing buffered to flush; Close must only be
+// called once nothing is publishing.
+func (p *Publisher) Close() error {
+	p.httpClient.CloseIdleConnections()
+	return p.rdb.Close()
+}
+
+// PingValkey checks that Valkey answers.
+func (p *Publisher) PingValkey(ctx context.Context) error {
+	return p.rdb.Ping(ctx).Err()
+}
+
+// PingParseable checks that Parseable is up. It asks the liveness
+// endpoint, which needs no credentials.
+func (p *Publisher) PingParseable(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.config().ParseableURL+"/api/v1/liveness", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("parseable returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SetFencer makes the publisher drop events unless f allows them. It must
+// be called before anything is published.
+func (p *Publisher) SetFencer(f Fencer) {
+	p.fencer = f
+}
+
+func (p *Publisher) config() *config.Config {
+	return p.cfg.Load()
+}
+
+// SetConfig switches the publisher to a reloaded configuration; the sink
+// toggles apply to the next event. Connections are not re-established.
+func (p *Publisher) SetConfig(cfg *config.Config) {
+	p.cfg.Store(cfg)
+}
+
+// publishLive sends an event to a Valkey channel unless the Valkey sink is
+// turned off.
+func (p *Publisher) publishLive(ctx context.Context, channel string, data []byte) error {
+	if !p.config().SinkValkey {
+		return nil
+	}
+	if p.fencer == nil {
+		start := time.Now()
+		err := p.rdb.Publish(ctx, channel, string(data)).Err()
+		observePublish(channelFamily(channel), sinkValkey, 1, start, err)
+		return err
+	}
+
+	key, token, ok := p.fencer.Fence()
+	if !ok {
+		publishFenced.With(sinkValkey).Inc()
+		return errFenced
+	}
+	start := time.Now()
+	n, err := fencedPublish.Run(ctx, p.rdb, []string{key}, token, channel, string(data)).Int64()
+	if err == nil && n < 0 {
+		publishFenced.With(sinkValkey).Inc()
+		return errFenced
+	}
+	observePublish(channelFamily(channel), sinkValkey, 1, start, err)
+	return err
+}
+
+// setLive stores data under key for ttl, under the same sink switch and
+// fence as publishLive.
+func (p *Publisher) setLive(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if !p.config().SinkValkey {
+		return nil
+	}
+	if p.fencer == nil {
+		return p.rdb.Set(ctx, key, string(data), ttl).Err()
+	}
+
+	fenceKey, token, ok := p.fencer.Fence()
+	if !ok {
+		publishFenced.With(sinkValkey).Inc()
+		return errFenced
+	}
+	n, err := fencedSet.Run(ctx, p.rdb, []string{fenceKey, key}, token, string(data), ttl.Milliseconds()).Int64()
+	if err == nil && n < 0 {
+		publishFenced.With(sinkValkey).Inc()
+		return errFenced
+	}
+	return err
 }
 
 func (p *Publisher) PublishTrainPosition(ctx context.Context, pos TrainPosition) error {
@@ -100,13 +334,13 @@ func (p *Publisher) PublishTrainPosition(ctx context.Context, pos TrainPosition)
 	}
 
 	channel := fmt.Sprintf("train:live:%s", pos.TrainNumber)
-	if err := p.rdb.Publish(ctx, channel, string(data)).Err(); err != nil {
+	if err := p.publishLive(ctx, channel, data); err != nil {
 		log.Printf("Warning: Valkey publish failed for %s: %v", channel, err)
 	}
 
 	if pos.CurrentStation != "" {
 		stationChannel := fmt.Sprintf("station:live:%s", pos.CurrentStation)
-		if err := p.rdb.Publish(ctx, stationChannel, string(data)).Err(); err != nil {
+		if err := p.publishLive(ctx, stationChannel, data); err != nil {
 			log.Printf("Warning: Valkey publish failed for %s: %v", stationChannel, err)
 		}
 	}
@@ -125,7 +359,7 @@ func (p *Publisher) PublishPlatformChange(ctx context.Context, event PlatformCha
 	}
 
 	channel := fmt.Sprintf("station:live:%s", event.StationCode)
-	if err := p.rdb.Publish(ctx, channel, string(data)).Err(); err != nil {
+	if err := p.publishLive(ctx, channel, data); err != nil {
 		log.Printf("Warning: Valkey publish failed: %v", err)
 	}
 
@@ -143,13 +377,31 @@ func (p *Publisher) PublishDelayEvent(ctx context.Context, event DelayEvent) err
 	}
 
 	channel := fmt.Sprintf("train:live:%s", event.TrainNumber)
-	if err := p.rdb.Publish(ctx, channel, string(data)).Err(); err != nil {
+	if err := p.publishLive(ctx, channel, data); err != nil {
 		log.Printf("Warning: Valkey publish failed: %v", err)
 	}
 
 	return nil
 }
 
+func (p *Publisher) PublishRunEvent(ctx context.Context, event RunEvent) error {
+	if err := p.ingestToParseable("run-events", []interface{}{event}); err != nil {
+		return fmt.Errorf("parseable ingest failed: %w", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json marshal failed: %w", err)
+	}
+
+	channel := fmt.Sprintf("train:live:%s", event.TrainNumber)
+	if err := p.publishLive(ctx, channel, data); err != nil {
+		log.Printf("Warning: Valkey publish failed for %s: %v", channel, err)
+	}
+
+	return nil
+}
+
 func (p *Publisher) PublishPnrStatusChange(ctx context.Context, event PnrStatusChange) error {
 	if err := p.ingestToParseable("pnr-status-changes", []interface{}{event}); err != nil {
 		return fmt.Errorf("parseable ingest failed: %w", err)
@@ -161,20 +413,175 @@ func (p *Publisher) PublishPnrStatusChange(ctx context.Context, event PnrStatusC
 	}
 
 	channel := fmt.Sprintf("pnr:update:%s", event.PNR)
-	if err := p.rdb.Publish(ctx, channel, string(data)).Err(); err != nil {
+	if err := p.publishLive(ctx, channel, data); err != nil {
 		log.Printf("Warning: Valkey publish failed: %v", err)
 	}
 
 	return nil
 }
 
+func (p *Publisher) PublishServiceDisruption(ctx context.Context, event ServiceDisruption) error {
+	if err := p.ingestToParseable("service-disruptions", []interface{}{event}); err != nil {
+		return fmt.Errorf("parseable ingest failed: %w", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json marshal failed: %w", err)
+	}
+
+	channel := fmt.Sprintf("train:live:%s", event.TrainNumber)
+	if err := p.publishLive(ctx, channel, data); err != nil {
+		log.Printf("Warning: Valkey publish failed for %s: %v", channel, err)
+	}
+
+	for _, code := range event.AffectedStations {
+		stationChannel := fmt.Sprintf("station:live:%s", code)
+		if err := p.publishLive(ctx, stationChannel, data); err != nil {
+			log.Printf("Warning: Valkey publish failed for %s: %v", stationChannel, err)
+		}
+	}
+
+	return nil
+}
+
+// PublishJourneyDisruption notifies a single user that a train they hold a
+// journey on is disrupted. It only goes to Valkey; the disruption itself is
+// already recorded in Parseable by PublishServiceDisruption.
+func (p *Publisher) PublishJourneyDisruption(ctx context.Context, event JourneyDisruption) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json marshal failed: %w", err)
+	}
+
+	channel := fmt.Sprintf("user:alert:%s", event.UserID)
+	if err := p.publishLive(ctx, channel, data); err != nil {
+		return fmt.Errorf("valkey publish failed for %s: %w", channel, err)
+	}
+
+	return nil
+}
+
+func (p *Publisher) PublishStationBoard(ctx context.Context, board StationBoard) error {
+	if err := p.ingestToParseable("station-boards", []interface{}{board}); err != nil {
+		return fmt.Errorf("parseable ingest failed: %w", err)
+	}
+
+	data, err := json.Marshal(board)
+	if err != nil {
+		return fmt.Errorf("json marshal failed: %w", err)
+	}
+
+	channel := fmt.Sprintf("station:live:%s", board.StationCode)
+	if err := p.publishLive(ctx, channel, data); err != nil {
+		log.Printf("Warning: Valkey publish failed for %s: %v", channel, err)
+	}
+
+	return nil
+}
+
+func (p *Publisher) PublishExpectedPlatform(ctx context.Context, event ExpectedPlatform) error {
+	if err := p.ingestToParseable("expected-platforms", []interface{}{event}); err != nil {
+		return fmt.Errorf("parseable ingest failed: %w", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json marshal failed: %w", err)
+	}
+
+	channel := fmt.Sprintf("station:live:%s", event.StationCode)
+	if err := p.publishLive(ctx, channel, data); err != nil {
+		log.Printf("Warning: Valkey publish failed: %v", err)
+	}
+
+	return nil
+}
+
+// PublishQuarantinedEvent records a rejected event in Parseable only; it is
+// never sent to live subscribers.
+func (p *Publisher) PublishQuarantinedEvent(ctx context.Context, event QuarantinedEvent) error {
+	if err := p.ingestToParseable("quarantined-events", []interface{}{event}); err != nil {
+		return fmt.Errorf("parseable ingest failed: %w", err)
+	}
+	return nil
+}
+
+// ChannelSubscribers returns the live subscriber count for each channel via
+// PUBSUB NUMSUB.
+func (p *Publisher) ChannelSubscribers(ctx context.Context, channels ...string) (map[string]int64, error) {
+	if len(channels) == 0 {
+		return map[string]int64{}, nil
+	}
+	return p.rdb.PubSubNumSub(ctx, channels...).Result()
+}
+
+// PopRefreshRequest blocks for up to timeout waiting for a refresh request.
+// It returns nil, nil when the wait times out or the payload is unusable.
+func (p *Publisher) PopRefreshRequest(ctx context.Context, key string, timeout time.Duration) (*RefreshRequest, error) {
+	res, err := p.rdb.BRPop(ctx, timeout, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var req RefreshRequest
+	// Without a correlation ID there is nowhere to send the reply.
+	if err := json.Unmarshal([]byte(res[1]), &req); err != nil || req.TrainNumber == "" || req.CorrelationID == "" {
+		log.Printf("Warning: Dropping malformed refresh request: %s", res[1])
+		return nil, nil
+	}
+	return &req, nil
+}
+
+// PublishRefreshReply answers a refresh request on
+// ingestion:refresh:reply:<correlation_id> and keeps a copy under the same
+// key so the API can read it if it subscribed after the reply went out.
+func (p *Publisher) PublishRefreshReply(ctx context.Context, reply RefreshReply) error {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return fmt.Errorf("json marshal failed: %w", err)
+	}
+
+	key := fmt.Sprintf("ingestion:refresh:reply:%s", reply.CorrelationID)
+	if err := p.setLive(ctx, key, data, refreshReplyTTL); err != nil {
+		log.Printf("Warning: Valkey set failed for %s: %v", key, err)
+	}
+	if err := p.publishLive(ctx, key, data); err != nil {
+		return fmt.Errorf("valkey publish failed for %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// ingestToParseable sends events to a Parseable stream unless the Parseable
+// sink is turned off.
 func (p *Publisher) ingestToParseable(stream string, events []interface{}) error {
+	if !p.config().SinkParseable {
+		return nil
+	}
+	if p.fencer != nil {
+		if _, _, ok := p.fencer.Fence(); !ok {
+			publishFenced.With(sinkParseable).Inc()
+			return errFenced
+		}
+	}
+	start := time.Now()
+	err := p.postToParseable(stream, events)
+	observePublish(stream, sinkParseable, len(events), start, err)
+	return err
+}
+
+// postToParseable sends events to a Parseable stream.
+func (p *Publisher) postToParseable(stream string, events []interface{}) error {
 	body, err := json.Marshal(events)
 	if err != nil {
 		return fmt.Errorf("json marshal failed: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/api/v1/logstream/%s", p.cfg.ParseableURL, stream)
+	url := fmt.Sprintf("%s/api/v1/logstream/%s", p.config().ParseableURL, stream)
 	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("create request failed: %w", err)
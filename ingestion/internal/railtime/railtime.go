@@ -0,0 +1,19 @@
+// Package railtime holds the calendar rules shared by the scraper, the mock
+// generator and the fake NTES server.
+package railtime
+
+import "time"
+
+// IST is Indian Standard Time, the zone timetables, runs_on patterns and
+// NTES pages are all written in.
+var IST = time.FixedZone("IST", 5*60*60+30*60)
+
+// RunsOnDay reports whether a runs_on pattern (index 0 = Monday, as in the
+// backend) includes t's weekday. An empty or malformed pattern counts as
+// daily.
+func RunsOnDay(runsOn string, t time.Time) bool {
+	if len(runsOn) != 7 {
+		return true
+	}
+	return runsOn[(int(t.Weekday())+6)%7] == '1'
+}
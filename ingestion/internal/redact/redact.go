@@ -0,0 +1,132 @@
+// Package redact keeps secrets out of log output.
+package redact
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces a secret in redacted output.
+const Placeholder = "[REDACTED]"
+
+// minLength is the shortest value worth redacting; anything shorter would
+// mangle ordinary words.
+const minLength = 4
+
+var (
+	mu       sync.RWMutex
+	secrets  = make(map[string]bool)
+	slots    = make(map[string][]string)
+	replacer = strings.NewReplacer()
+)
+
+// Add registers secrets to be redacted from everything written through a
+// Writer from now on. Secrets that are replaced over time, such as session
+// tokens, belong in a slot instead; see Set.
+func Add(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	changed := false
+	for _, v := range values {
+		if len(v) >= minLength && !secrets[v] {
+			secrets[v] = true
+			changed = true
+		}
+	}
+	if changed {
+		rebuild()
+	}
+}
+
+// Set registers the secrets held in the named slot, replacing whatever the
+// slot held before. A session token is Set under one name each time it is
+// issued, so the redaction set does not grow with every new session.
+func Set(slot string, values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	var kept []string
+	for _, v := range values {
+		if len(v) >= minLength {
+			kept = append(kept, v)
+		}
+	}
+	if equal(slots[slot], kept) {
+		return
+	}
+	if len(kept) == 0 {
+		delete(slots, slot)
+	} else {
+		slots[slot] = kept
+	}
+	rebuild()
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rebuild replaces the replacer with one for every registered secret. The
+// caller holds mu.
+func rebuild() {
+	seen := make(map[string]bool, len(secrets))
+	all := make([]string, 0, len(secrets))
+	for v := range secrets {
+		seen[v] = true
+		all = append(all, v)
+	}
+	for _, values := range slots {
+		for _, v := range values {
+			if !seen[v] {
+				seen[v] = true
+				all = append(all, v)
+			}
+		}
+	}
+	// Longest first, so a secret containing another is redacted whole.
+	sort.Slice(all, func(i, j int) bool {
+		if len(all[i]) != len(all[j]) {
+			return len(all[i]) > len(all[j])
+		}
+		return all[i] < all[j]
+	})
+	pairs := make([]string, 0, 2*len(all))
+	for _, v := range all {
+		pairs = append(pairs, v, Placeholder)
+	}
+	replacer = strings.NewReplacer(pairs...)
+}
+
+// String returns s with every registered secret replaced.
+func String(s string) string {
+	mu.RLock()
+	r := replacer
+	mu.RUnlock()
+	return r.Replace(s)
+}
+
+// Writer redacts registered secrets from each write. The log package
+// writes one entry per call, so a secret is never split across writes.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (rw *Writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
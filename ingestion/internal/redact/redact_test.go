@@ -0,0 +1,21 @@
+package redact
+
+import "testing"
+
+func TestSetReplacesSlot(t *testing.T) {
+	Add("config-password")
+	Set("session", "first-token")
+	Set("session", "second-token")
+
+	got := String("config-password first-token second-token")
+	if want := Placeholder + " first-token " + Placeholder; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	mu.RLock()
+	n := len(slots["session"])
+	mu.RUnlock()
+	if n != 1 {
+		t.Errorf("slot holds %d secrets after two sessions, want 1", n)
+	}
+}
@@ -0,0 +1,163 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/railtime"
+)
+
+// notRunningLead is how long before its next scheduled departure a train
+// that was not running is scraped again.
+const notRunningLead = 30 * time.Minute
+
+// recordOutcome updates a train's failure bookkeeping after a scrape and
+// pushes its next scrape back when it failed, returned nothing usable, or is
+// known not to be running today.
+func (s *Scraper) recordOutcome(train TrainInfo, err error, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.stateLocked(train.Number)
+	st.Train = train
+
+	notRunning := errors.Is(err, errNotRunning) ||
+		(errors.Is(err, errNoRunningData) && !railtime.RunsOnDay(train.RunsOn, now.In(railtime.IST)))
+
+	switch {
+	case err == nil:
+		st.Failures, st.Empty = 0, 0
+		st.LastError = ""
+		st.NotRunningUntil = time.Time{}
+		return
+	case notRunning:
+		dataAge.Delete(train.Number)
+		st.Empty++
+		st.NotRunningUntil = nextDeparture(train, now).Add(-notRunningLead)
+		log.Printf("%s (%s) is not running today, next scrape after %s",
+			train.Number, train.Name, st.NotRunningUntil.In(railtime.IST).Format("02-Jan 15:04"))
+		return
+	case errors.Is(err, errNoRunningData), errors.Is(err, errNoValidEvents):
+		st.Empty++
+	default:
+		st.Failures++
+	}
+	st.LastError = err.Error()
+	st.LastErrorAt = now
+
+	if due := now.Add(s.backoff(st.Failures + st.Empty)); due.After(st.NextDue) {
+		st.NextDue = due
+	}
+}
+
+// backoff is the wait after n consecutive unsuccessful scrapes: one poll
+// interval, doubling each time, capped at ScrapeBackoffMax.
+func (s *Scraper) backoff(n int) time.Duration {
+	cycle := time.Duration(s.config().PollInterval) * time.Second
+	limit := time.Duration(s.config().ScrapeBackoffMax) * time.Second
+	if n <= 0 {
+		return 0
+	}
+	d := cycle
+	for i := 1; i < n && (limit <= 0 || d < limit); i++ {
+		d *= 2
+	}
+	if limit > 0 && d > limit {
+		d = limit
+	}
+	return d
+}
+
+// fullyCancelled reports whether the disruptions include a notice that
+// cancels the whole run. A partial cancellation, however much of the route
+// it covers, leaves the train running somewhere.
+func fullyCancelled(ds []Disruption) bool {
+	for _, d := range ds {
+		if d.Kind == DisruptionCancelled {
+			return true
+		}
+	}
+	return false
+}
+
+// nextDeparture is the train's first scheduled departure from its source on
+// a day after now's (IST) date. Without a usable timetable it falls back to
+// the start of the next day.
+func nextDeparture(train TrainInfo, now time.Time) time.Time {
+	local := now.In(railtime.IST)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, railtime.IST)
+
+	dep, ok := parseClock(train.SourceDeparture)
+	if !ok {
+		return midnight.AddDate(0, 0, 1)
+	}
+	for d := 1; d <= 7; d++ {
+		day := midnight.AddDate(0, 0, d)
+		if railtime.RunsOnDay(train.RunsOn, day) {
+			return day.Add(time.Duration(dep) * time.Minute)
+		}
+	}
+	return midnight.AddDate(0, 0, 1)
+}
+
+// TrainStatus is a snapshot of the scraper's bookkeeping for one train.
+type TrainStatus struct {
+	TrainNumber     string    `json:"train_number"`
+	Name            string    `json:"name"`
+	Score           int       `json:"demand_score"`
+	LastScrape      time.Time `json:"last_scrape"`
+	NextDue         time.Time `json:"next_due"`
+	Failures        int       `json:"consecutive_failures"`
+	Empty           int       `json:"consecutive_empty"`
+	LastError       string    `json:"last_error,omitempty"`
+	LastErrorAt     time.Time `json:"last_error_at"`
+	NotRunningUntil time.Time `json:"not_running_until"`
+}
+
+// Status returns the scraper's per-train state, ordered by train number.
+func (s *Scraper) Status() []TrainStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]TrainStatus, 0, len(s.states))
+	for _, st := range s.states {
+		out = append(out, TrainStatus{
+			TrainNumber:     st.Train.Number,
+			Name:            st.Train.Name,
+			Score:           st.Demand.Score(),
+			LastScrape:      st.LastScrape,
+			NextDue:         st.NextDue,
+			Failures:        st.Failures,
+			Empty:           st.Empty,
+			LastError:       st.LastError,
+			LastErrorAt:     st.LastErrorAt,
+			NotRunningUntil: st.NotRunningUntil,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].TrainNumber < out[j].TrainNumber })
+	return out
+}
+
+// DumpStatus writes Status as a table.
+func (s *Scraper) DumpStatus(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TRAIN\tSCORE\tLAST SCRAPE\tNEXT DUE\tFAIL\tEMPTY\tNOT RUNNING UNTIL\tLAST ERROR")
+	for _, st := range s.Status() {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			st.TrainNumber, st.Score, clockOrDash(st.LastScrape), clockOrDash(st.NextDue),
+			st.Failures, st.Empty, clockOrDash(st.NotRunningUntil), st.LastError)
+	}
+	tw.Flush()
+}
+
+func clockOrDash(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.In(railtime.IST).Format("02-Jan 15:04:05")
+}
@@ -0,0 +1,132 @@
+package scraper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/railtime"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		max  int
+		n    int
+		want time.Duration
+	}{
+		{1800, 0, 0},
+		{1800, 1, time.Minute},
+		{1800, 2, 2 * time.Minute},
+		{1800, 5, 16 * time.Minute},
+		{1800, 6, 30 * time.Minute},
+		{1800, 40, 30 * time.Minute},
+		{0, 8, 128 * time.Minute}, // uncapped
+	}
+	for _, tt := range tests {
+		cfg := config.Defaults()
+		cfg.PollInterval = 60
+		cfg.ScrapeBackoffMax = tt.max
+		if got := New(cfg, nil).backoff(tt.n); got != tt.want {
+			t.Errorf("backoff(%d) with max %ds = %s, want %s", tt.n, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestFullyCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		ds   []Disruption
+		want bool
+	}{
+		{"none", nil, false},
+		{"cancelled", []Disruption{{Kind: DisruptionCancelled}}, true},
+		{"partial over the whole route", []Disruption{{Kind: DisruptionPartiallyCancelled, AffectedStations: []string{"HWH", "ASN", "DHN"}}}, false},
+		{"short terminated", []Disruption{{Kind: DisruptionShortTerminated, AffectedStations: []string{"DHN"}}}, false},
+		{"rescheduled and cancelled", []Disruption{{Kind: DisruptionRescheduled}, {Kind: DisruptionCancelled}}, true},
+	}
+	for _, tt := range tests {
+		if got := fullyCancelled(tt.ds); got != tt.want {
+			t.Errorf("%s: fullyCancelled = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecordOutcome(t *testing.T) {
+	// A Monday, 10:00 IST.
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, railtime.IST)
+	daily := TrainInfo{Number: "12301", SourceDeparture: "16:50"}
+	weekends := TrainInfo{Number: "22691", SourceDeparture: "20:00", RunsOn: "0000011"}
+
+	tests := []struct {
+		name            string
+		train           TrainInfo
+		errs            []error
+		wantNextDue     time.Time
+		wantNotRunning  time.Time
+		wantFailures    int
+		wantEmpty       int
+		wantLastErrored bool
+	}{
+		{
+			name:            "failures back off",
+			train:           daily,
+			errs:            []error{errors.New("timeout"), errors.New("timeout"), errors.New("timeout")},
+			wantNextDue:     now.Add(4 * time.Minute),
+			wantFailures:    3,
+			wantLastErrored: true,
+		},
+		{
+			name:            "empty answers back off",
+			train:           daily,
+			errs:            []error{errNoRunningData, errNoValidEvents},
+			wantNextDue:     now.Add(2 * time.Minute),
+			wantEmpty:       2,
+			wantLastErrored: true,
+		},
+		{
+			name:  "success resets",
+			train: daily,
+			errs:  []error{errors.New("timeout"), errNoRunningData, nil},
+			// The backoff already scheduled stands; only the counters reset.
+			wantNextDue: now.Add(2 * time.Minute),
+		},
+		{
+			name:           "cancelled sleeps until the next departure",
+			train:          daily,
+			errs:           []error{errNotRunning},
+			wantNotRunning: time.Date(2026, 10, 20, 16, 20, 0, 0, railtime.IST),
+			wantEmpty:      1,
+		},
+		{
+			name:           "no data on a day off sleeps",
+			train:          weekends,
+			errs:           []error{errNoRunningData},
+			wantNotRunning: time.Date(2026, 10, 24, 19, 30, 0, 0, railtime.IST),
+			wantEmpty:      1,
+		},
+	}
+
+	for _, tt := range tests {
+		cfg := config.Defaults()
+		cfg.PollInterval = 60
+		s := New(cfg, nil)
+		for _, err := range tt.errs {
+			s.recordOutcome(tt.train, err, now)
+		}
+
+		st := s.states[tt.train.Number]
+		if !st.NextDue.Equal(tt.wantNextDue) {
+			t.Errorf("%s: NextDue = %s, want %s", tt.name, st.NextDue, tt.wantNextDue)
+		}
+		if !st.NotRunningUntil.Equal(tt.wantNotRunning) {
+			t.Errorf("%s: NotRunningUntil = %s, want %s", tt.name, st.NotRunningUntil, tt.wantNotRunning)
+		}
+		if st.Failures != tt.wantFailures || st.Empty != tt.wantEmpty {
+			t.Errorf("%s: Failures, Empty = %d, %d, want %d, %d", tt.name, st.Failures, st.Empty, tt.wantFailures, tt.wantEmpty)
+		}
+		if (st.LastError != "") != tt.wantLastErrored {
+			t.Errorf("%s: LastError = %q", tt.name, st.LastError)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/publisher"
+)
+
+// Disruption kinds, as published in ServiceDisruption.DisruptionType.
+const (
+	DisruptionCancelled          = "cancelled"
+	DisruptionPartiallyCancelled = "partially_cancelled"
+	DisruptionRescheduled        = "rescheduled"
+	DisruptionDiverted           = "diverted"
+	DisruptionShortTerminated    = "short_terminated"
+)
+
+var (
+	// "Cancelled", "Fully Cancelled", "Train Cancelled on 12-Oct-2026",
+	// "Train is Cancelled due to ...". Only a line that opens with the
+	// cancellation counts, so "Cancelled stoppage at ..." or a note about
+	// cancelled reservations does not cancel the whole run.
+	cancelledRe = regexp.MustCompile(`(?i)^(?:train\s+(?:is\s+|has\s+been\s+)?|fully\s+)?cancell?ed(?:\s*[.!]?$|\s+(?:on|for|from|today|due)\b)`)
+	// "Partially Cancelled between Name (CODE) and Name (CODE)"
+	partialCancelRe = regexp.MustCompile(`(?i)partially\s+cancell?ed\s+between\s+.+?\((\w+)\)\s+and\s+.+?\((\w+)\)`)
+	// "Rescheduled by 02:30 hrs. New Departure: 19:25 12-Oct"
+	rescheduledRe = regexp.MustCompile(`(?i)rescheduled.*?new\s+departure(?:\s+time)?\s*:?\s*(\d{2}:\d{2})(?:\s+(\d{2}-\w{3}))?`)
+	// "Diverted via Name (CODE), Name (CODE)"
+	divertedRe = regexp.MustCompile(`(?i)diverted\s+via\s+(.+)`)
+	// "Short Terminated at Name (CODE)"
+	shortTerminatedRe = regexp.MustCompile(`(?i)short\s+terminated\s+at\s+.+?\((\w+)\)`)
+	stationCodeRe     = regexp.MustCompile(`\((\w+)\)`)
+)
+
+// Disruption spans describe which part of the route a notice covers before
+// it is resolved against train_routes.
+const (
+	spanListed  = iota // exactly the stations named in the notice
+	spanRoute          // every stop on the route
+	spanSource         // the source station only
+	spanBetween        // stops between the two named stations
+	spanAfter          // stops after the named station
+)
+
+type Disruption struct {
+	Kind             string
+	AffectedStations []string
+	NewDepartureTime string
+	Details          string
+
+	span int
+}
+
+// parseNTESNotices extracts cancellation, rescheduling, diversion and short
+// termination notices from a TrainRunning page.
+func parseNTESNotices(html string) []Disruption {
+	var out []Disruption
+
+	for _, line := range strings.Split(html, "\n") {
+		line = strings.Join(strings.Fields(stripTags(line)), " ")
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case partialCancelRe.MatchString(line):
+			m := partialCancelRe.FindStringSubmatch(line)
+			out = append(out, Disruption{
+				Kind:             DisruptionPartiallyCancelled,
+				AffectedStations: []string{m[1], m[2]},
+				Details:          line,
+				span:             spanBetween,
+			})
+		case shortTerminatedRe.MatchString(line):
+			m := shortTerminatedRe.FindStringSubmatch(line)
+			out = append(out, Disruption{
+				Kind:             DisruptionShortTerminated,
+				AffectedStations: []string{m[1]},
+				Details:          line,
+				span:             spanAfter,
+			})
+		case rescheduledRe.MatchString(line):
+			m := rescheduledRe.FindStringSubmatch(line)
+			out = append(out, Disruption{
+				Kind:             DisruptionRescheduled,
+				NewDepartureTime: strings.TrimSpace(m[1] + " " + m[2]),
+				Details:          line,
+				span:             spanSource,
+			})
+		case divertedRe.MatchString(line):
+			m := divertedRe.FindStringSubmatch(line)
+			var via []string
+			for _, cm := range stationCodeRe.FindAllStringSubmatch(m[1], -1) {
+				via = append(via, cm[1])
+			}
+			out = append(out, Disruption{
+				Kind:             DisruptionDiverted,
+				AffectedStations: via,
+				Details:          line,
+			})
+		case cancelledRe.MatchString(line):
+			out = append(out, Disruption{
+				Kind:    DisruptionCancelled,
+				Details: line,
+				span:    spanRoute,
+			})
+		}
+	}
+
+	return out
+}
+
+// resolveDisruptions expands notices that name only an endpoint into the
+// full list of affected stops on route.
+func resolveDisruptions(disruptions []Disruption, route []RouteStop) []Disruption {
+	for i := range disruptions {
+		d := &disruptions[i]
+		switch d.span {
+		case spanRoute:
+			d.AffectedStations = routeCodes(route)
+		case spanSource:
+			if len(route) > 0 {
+				d.AffectedStations = []string{route[0].StationCode}
+			}
+		case spanBetween:
+			d.AffectedStations = routeBetween(route, d.AffectedStations[0], d.AffectedStations[1])
+		case spanAfter:
+			d.AffectedStations = routeAfter(route, d.AffectedStations[0])
+		}
+		d.span = spanListed
+	}
+	return disruptions
+}
+
+// collectDisruptions resolves the notices in status against route and adds
+// a diversion for any events reported off the route. Off-route stations are
+// merged into a diversion notice NTES already gave, so the train has one
+// diversion with a stable signature rather than two that take turns.
+func collectDisruptions(status *RunningStatus, route []RouteStop) []Disruption {
+	disruptions := resolveDisruptions(status.Disruptions, route)
+
+	var offRoute *Disruption
+	for _, inst := range status.Instances {
+		if offRoute = detectOffRoute(inst.Events, route); offRoute != nil {
+			break
+		}
+	}
+	if offRoute == nil {
+		return disruptions
+	}
+	for i := range disruptions {
+		d := &disruptions[i]
+		if d.Kind != DisruptionDiverted {
+			continue
+		}
+		listed := make(map[string]bool, len(d.AffectedStations))
+		for _, code := range d.AffectedStations {
+			listed[code] = true
+		}
+		for _, code := range offRoute.AffectedStations {
+			if !listed[code] {
+				d.AffectedStations = append(d.AffectedStations, code)
+			}
+		}
+		return disruptions
+	}
+	return append(disruptions, *offRoute)
+}
+
+// detectOffRoute reports events at stations that are not on the train's
+// timetabled route, which NTES does not announce separately when a train
+// is diverted.
+func detectOffRoute(events []RunningEvent, route []RouteStop) *Disruption {
+	if len(route) == 0 {
+		return nil
+	}
+	onRoute := make(map[string]bool, len(route))
+	for _, stop := range route {
+		onRoute[stop.StationCode] = true
+	}
+
+	seen := make(map[string]bool)
+	var offRoute []string
+	for _, ev := range events {
+		if !onRoute[ev.StationCode] && !seen[ev.StationCode] {
+			seen[ev.StationCode] = true
+			offRoute = append(offRoute, ev.StationCode)
+		}
+	}
+	if len(offRoute) == 0 {
+		return nil
+	}
+
+	return &Disruption{
+		Kind:             DisruptionDiverted,
+		AffectedStations: offRoute,
+		Details:          fmt.Sprintf("Reported at stations outside its route: %s", strings.Join(offRoute, ", ")),
+	}
+}
+
+// publishDisruptions publishes disruptions that differ from what was last
+// published for the train, stamped with observedAt, and alerts users
+// travelling on it today. When reprocessing, the notices are old news: the
+// journeys travelling today are not the ones they affected, so nobody is
+// alerted.
+func (s *Scraper) publishDisruptions(ctx context.Context, train TrainInfo, disruptions []Disruption, observedAt time.Time, reprocess bool) {
+	if len(disruptions) == 0 {
+		return
+	}
+
+	for _, d := range disruptions {
+		key := train.Number + ":" + d.Kind
+		sig := disruptionSignature(d)
+		if s.lastDisruption[key] == sig {
+			continue
+		}
+
+		var journeys []journeyRef
+		if !reprocess {
+			var err error
+			journeys, err = s.getTravellingJourneys(train.Number)
+			if err != nil {
+				log.Printf("Failed to look up journeys on %s: %v", train.Number, err)
+			}
+		}
+
+		ev := publisher.ServiceDisruption{
+			EventType:        "service_disruption",
+			TrainNumber:      train.Number,
+			DisruptionType:   d.Kind,
+			AffectedStations: d.AffectedStations,
+			NewDepartureTime: d.NewDepartureTime,
+			Details:          d.Details,
+			AffectedJourneys: len(journeys),
+			Timestamp:        observedAt.UTC().Format(time.RFC3339),
+		}
+		if err := s.pub.PublishServiceDisruption(ctx, ev); err != nil {
+			log.Printf("Failed to publish disruption for %s: %v", train.Number, err)
+			continue
+		}
+		s.lastDisruption[key] = sig
+		log.Printf("[REAL] %s (%s): %s affecting %d stations, %d journeys",
+			train.Number, train.Name, d.Kind, len(d.AffectedStations), len(journeys))
+
+		for _, j := range journeys {
+			if j.UserID == "" {
+				continue
+			}
+			alert := publisher.JourneyDisruption{
+				UserID:      j.UserID,
+				JourneyID:   j.ID,
+				PNR:         j.PNR,
+				TrainNumber: train.Number,
+				Disruption:  ev,
+			}
+			if err := s.pub.PublishJourneyDisruption(ctx, alert); err != nil {
+				log.Printf("Failed to alert user %s about %s: %v", j.UserID, train.Number, err)
+			}
+		}
+	}
+}
+
+// forgetDisruptions drops the published signatures of the train's
+// disruption kinds that are missing from disruptions, so a notice that is
+// lifted and later reissued is published again. Only a page that would
+// carry the notices can say they are gone.
+func (s *Scraper) forgetDisruptions(train TrainInfo, disruptions []Disruption) {
+	current := make(map[string]bool, len(disruptions))
+	for _, d := range disruptions {
+		current[d.Kind] = true
+	}
+	for _, kind := range []string{DisruptionCancelled, DisruptionPartiallyCancelled, DisruptionRescheduled, DisruptionDiverted, DisruptionShortTerminated} {
+		if !current[kind] {
+			delete(s.lastDisruption, train.Number+":"+kind)
+		}
+	}
+}
+
+func disruptionSignature(d Disruption) string {
+	stations := append([]string(nil), d.AffectedStations...)
+	sort.Strings(stations)
+	return strings.Join(stations, ",") + "|" + d.NewDepartureTime
+}
+
+func routeCodes(route []RouteStop) []string {
+	codes := make([]string, 0, len(route))
+	for _, stop := range route {
+		codes = append(codes, stop.StationCode)
+	}
+	return codes
+}
+
+func routeBetween(route []RouteStop, from, to string) []string {
+	var codes []string
+	inside := false
+	for _, stop := range route {
+		if stop.StationCode == from {
+			inside = true
+		}
+		if inside {
+			codes = append(codes, stop.StationCode)
+		}
+		if stop.StationCode == to && inside {
+			break
+		}
+	}
+	if len(codes) == 0 {
+		return []string{from, to}
+	}
+	return codes
+}
+
+// routeAfter returns the stops the train will no longer reach when it is
+// short terminated at code.
+func routeAfter(route []RouteStop, code string) []string {
+	for i, stop := range route {
+		if stop.StationCode == code {
+			return routeCodes(route[i+1:])
+		}
+	}
+	return []string{code}
+}
+
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+func stripTags(s string) string {
+	return tagRe.ReplaceAllString(s, " ")
+}
@@ -0,0 +1,97 @@
+package scraper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+)
+
+func TestParseNTESNotices(t *testing.T) {
+	tests := []struct {
+		line string
+		want []Disruption // Details is checked separately
+	}{
+		{"<div class='notice'>Train Cancelled</div>", []Disruption{{Kind: DisruptionCancelled, span: spanRoute}}},
+		{"<div class='notice'>Fully Cancelled</div>", []Disruption{{Kind: DisruptionCancelled, span: spanRoute}}},
+		{"<div>Train Cancelled on 12-Oct-2026</div>", []Disruption{{Kind: DisruptionCancelled, span: spanRoute}}},
+		{"<div>Train is cancelled due to operational reasons.</div>", []Disruption{{Kind: DisruptionCancelled, span: spanRoute}}},
+		{"<div>CANCELLED</div>", []Disruption{{Kind: DisruptionCancelled, span: spanRoute}}},
+		{
+			"<div>Partially Cancelled between Howrah Jn (HWH) and Asansol Jn (ASN)</div>",
+			[]Disruption{{Kind: DisruptionPartiallyCancelled, AffectedStations: []string{"HWH", "ASN"}, span: spanBetween}},
+		},
+		{
+			"<div>Short Terminated at Dhanbad Jn (DHN)</div>",
+			[]Disruption{{Kind: DisruptionShortTerminated, AffectedStations: []string{"DHN"}, span: spanAfter}},
+		},
+		{
+			"<div>Rescheduled by 02:30 hrs. New Departure: 19:20 12-Oct</div>",
+			[]Disruption{{Kind: DisruptionRescheduled, NewDepartureTime: "19:20 12-Oct", span: spanSource}},
+		},
+		{
+			"<div>Diverted via Agra Cantt (AGC), Gwalior (GWL)</div>",
+			[]Disruption{{Kind: DisruptionDiverted, AffectedStations: []string{"AGC", "GWL"}}},
+		},
+
+		// Notices that mention a cancellation without cancelling the run.
+		{"<div>Cancelled stoppage at Asansol Jn (ASN)</div>", nil},
+		{"<div>Cancelled Stoppage: Dhanbad Jn (DHN) on 12-Oct-2026</div>", nil},
+		{"<div>Reservation cancelled tickets will be refunded automatically.</div>", nil},
+		{"<div>Refund for cancelled trains is credited to the original account.</div>", nil},
+		{"<div>Coach B3 cancelled, passengers accommodated in B4</div>", nil},
+		{"<div>Departed from Howrah Jn (HWH) at 16:55 12-Oct Delay: 00:05 PF 9</div>", nil},
+	}
+
+	for _, tt := range tests {
+		got := parseNTESNotices(tt.line)
+		for i := range got {
+			if got[i].Details == "" {
+				t.Errorf("%q: disruption %d has no details", tt.line, i)
+			}
+			got[i].Details = ""
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q:\n got %+v\nwant %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestDiversionPublishedOnce(t *testing.T) {
+	route := []RouteStop{
+		{StationCode: "NDLS", StopNumber: 1},
+		{StationCode: "MTJ", StopNumber: 2},
+		{StationCode: "AGC", StopNumber: 3},
+		{StationCode: "GWL", StopNumber: 4},
+	}
+	// NTES names the diversion and the train has already been reported at
+	// the first station of it, which the route does not know either.
+	page := "<div>Diverted via Agra Fort (AF), Tundla Jn (TDL)</div>\n" +
+		"<div>Departed from New Delhi (NDLS) at 06:00 12-Oct On Time</div>\n" +
+		"<div>Departed from Agra Fort (AF) at 09:10 12-Oct Delay: 00:20</div>\n"
+
+	rec := &recorder{}
+	s := New(config.Defaults(), nil)
+	s.pub = rec
+	s.db = refusingDB(t)
+	train := TrainInfo{Number: "12002", Name: "Bhopal Shatabdi"}
+
+	for i := 0; i < 2; i++ {
+		status, err := parseResponse(sourceNTES, []byte(page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		disruptions := collectDisruptions(status, route)
+		s.publishDisruptions(context.Background(), train, disruptions, time.Now(), false)
+		s.forgetDisruptions(train, disruptions)
+	}
+
+	if len(rec.disruptions) != 1 {
+		t.Fatalf("published %d disruptions over two identical scrapes, want 1: %+v", len(rec.disruptions), rec.disruptions)
+	}
+	if got, want := rec.disruptions[0].AffectedStations, []string{"AF", "TDL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AffectedStations = %v, want %v", got, want)
+	}
+}
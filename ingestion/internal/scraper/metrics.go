@@ -0,0 +1,70 @@
+package scraper
+
+import (
+	"time"
+
+	"github.com/rail-app/ingestion/internal/metrics"
+)
+
+// sourceStationBoard labels NTES station board fetches, which are not
+// train scrapes, in metrics.
+const sourceStationBoard = "ntes_station_board"
+
+// Scrape outcomes, as labelled in metrics.
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// cycleBuckets span a cycle of a handful of trains to one over budget.
+var cycleBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600}
+
+var (
+	scrapeRequests = metrics.NewCounterVec("ingestion_scrape_requests_total",
+		"Upstream fetches of a train's running status, by source and result.", "source", "result")
+	scrapeDuration = metrics.NewHistogramVec("ingestion_scrape_duration_seconds",
+		"Time taken by one upstream fetch, by source.", metrics.DurationBuckets, "source")
+	scrapeFallbacks = metrics.NewCounter("ingestion_scrape_fallbacks_total",
+		"Train scrapes where NTES failed and eRail was tried; divide by ingestion_train_scrapes_total for the fallback rate.")
+	trainScrapes = metrics.NewCounter("ingestion_train_scrapes_total",
+		"Train scrapes, whatever the source or outcome.")
+	parseEmpty = metrics.NewCounterVec("ingestion_parse_empty_total",
+		"Upstream answers that parsed to no running events, by source.", "source")
+
+	cycleDuration = metrics.NewHistogramVec("ingestion_scrape_cycle_duration_seconds",
+		"Time taken by one polling cycle, by loop.", cycleBuckets, "loop")
+	cycleInterval = metrics.NewGaugeVec("ingestion_scrape_cycle_interval_seconds",
+		"Configured time between polling cycles, by loop.", "loop")
+	cycleOverruns = metrics.NewCounterVec("ingestion_scrape_cycle_overruns_total",
+		"Polling cycles that took longer than their interval, by loop.", "loop")
+
+	dataAge = metrics.NewAgeVec("ingestion_train_data_age_seconds",
+		"Seconds since the freshest running data published for each train was fetched.", "train")
+)
+
+// Polling loops, as labelled in metrics.
+const (
+	loopTrains        = "trains"
+	loopStationBoards = "station_boards"
+)
+
+// observeCycle records a polling cycle that started at start against its
+// configured interval in seconds.
+func observeCycle(loop string, start time.Time, interval int) {
+	took := time.Since(start)
+	cycleDuration.With(loop).Observe(took.Seconds())
+	cycleInterval.With(loop).Set(float64(interval))
+	if took > time.Duration(interval)*time.Second {
+		cycleOverruns.With(loop).Inc()
+	}
+}
+
+// observeFetch records one upstream fetch that started at start.
+func observeFetch(source string, start time.Time, err error) {
+	scrapeDuration.With(source).ObserveSince(start)
+	if err != nil {
+		scrapeRequests.With(source, resultFailure).Inc()
+		return
+	}
+	scrapeRequests.With(source, resultSuccess).Inc()
+}
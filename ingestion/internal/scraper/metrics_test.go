@@ -0,0 +1,61 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/metrics"
+)
+
+func hasDataAge(trainNumber string) bool {
+	var buf bytes.Buffer
+	metrics.WriteTo(&buf)
+	return strings.Contains(buf.String(), `ingestion_train_data_age_seconds{train="`+trainNumber+`"}`)
+}
+
+func TestDataAgeDroppedWhenRunEnds(t *testing.T) {
+	s := New(config.Defaults(), nil)
+	s.pub = &recorder{}
+	s.db = refusingDB(t)
+	train := TrainInfo{Number: "12301", Name: "Howrah Rajdhani", DestStation: "NDLS"}
+	today := time.Now().Format("2006-01-02")
+
+	running := &RunningStatus{Source: sourceNTES, Instances: []RunningInstance{{StartDate: today, Events: []RunningEvent{
+		{Type: "Departed", StationCode: "HWH", Time: "16:55"},
+	}}}}
+	if err := s.handleStatus(context.Background(), train, running, time.Now(), false); err != nil {
+		t.Fatal(err)
+	}
+	if !hasDataAge(train.Number) {
+		t.Fatal("no data age for a train on its way")
+	}
+
+	arrived := &RunningStatus{Source: sourceNTES, Instances: []RunningInstance{{StartDate: today, Events: []RunningEvent{
+		{Type: "Departed", StationCode: "HWH", Time: "16:55"},
+		{Type: "Arrived", StationCode: "NDLS", Time: "09:55"},
+	}}}}
+	if err := s.handleStatus(context.Background(), train, arrived, time.Now(), false); err != nil {
+		t.Fatal(err)
+	}
+	if hasDataAge(train.Number) {
+		t.Error("data age still exported after the run reached its destination")
+	}
+}
+
+func TestDataAgeDroppedWhenTrainLeavesTimetable(t *testing.T) {
+	s := New(config.Defaults(), nil)
+	dataAge.With("12951").Observe(time.Now())
+	s.rankTrains([]TrainInfo{{Number: "12951"}}, nil, nil, time.Now())
+	if !hasDataAge("12951") {
+		t.Fatal("data age dropped for a train still in the timetable")
+	}
+
+	s.rankTrains(nil, nil, nil, time.Now())
+	if hasDataAge("12951") {
+		t.Error("data age still exported for a train no longer in the timetable")
+	}
+}
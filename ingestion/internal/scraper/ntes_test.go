@@ -0,0 +1,233 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/fakentes"
+)
+
+var testTrain = fakentes.Train{
+	Number: "12301",
+	Name:   "Howrah Rajdhani",
+	Stops: []fakentes.Stop{
+		{StationCode: "HWH", StationName: "Howrah Jn", Departure: "16:50", DayNumber: 1, Platform: "9"},
+		{StationCode: "ASN", StationName: "Asansol Jn", Arrival: "18:57", Departure: "18:59", DayNumber: 1, Distance: 200},
+		{StationCode: "DHN", StationName: "Dhanbad Jn", Arrival: "19:55", DayNumber: 1, Distance: 259},
+	},
+}
+
+// newFakeNTES starts fakentes with testTrain and returns a scraper pointed
+// at it. The fake's clock runs two days ahead, so every stop of today's run
+// has been reached whatever the time of day.
+func newFakeNTES(t *testing.T, opts fakentes.Options) (*Scraper, *fakentes.Server) {
+	t.Helper()
+	if opts.Now == nil {
+		opts.Now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	}
+	fake := fakentes.New([]fakentes.Train{testTrain}, opts)
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	cfg := config.Defaults()
+	cfg.NTESBaseURL = srv.URL
+	cfg.ScrapeRequestDelay = 0
+	return New(cfg, nil), fake
+}
+
+func TestInitNTESSession(t *testing.T) {
+	s, _ := newFakeNTES(t, fakentes.Options{})
+
+	if err := s.initNTESSession(context.Background()); err != nil {
+		t.Fatalf("initNTESSession: %v", err)
+	}
+	if !strings.HasPrefix(s.csrfKey, "csrf") || s.csrfValue == "" {
+		t.Errorf("CSRF token = %q=%q, want a csrf-prefixed key and a value", s.csrfKey, s.csrfValue)
+	}
+}
+
+func TestFetchFromNTES(t *testing.T) {
+	s, _ := newFakeNTES(t, fakentes.Options{Delay: fakentes.DelayModel{BaseMinutes: 5, PerStopMinutes: 10}})
+	ctx := context.Background()
+	if err := s.initNTESSession(ctx); err != nil {
+		t.Fatalf("initNTESSession: %v", err)
+	}
+
+	status, err := s.fetchFromNTES(ctx, testTrain.Number)
+	if err != nil {
+		t.Fatalf("fetchFromNTES: %v", err)
+	}
+	if status.Source != sourceNTES {
+		t.Errorf("Source = %q, want %q", status.Source, sourceNTES)
+	}
+	if len(status.Instances) != 1 {
+		t.Fatalf("got %d instances, want 1", len(status.Instances))
+	}
+
+	inst := status.Instances[0]
+	if want := time.Now().Format("2006-01-02"); inst.StartDate != want {
+		t.Errorf("StartDate = %q, want %q", inst.StartDate, want)
+	}
+	want := []RunningEvent{
+		{Type: "Departed", StationName: "Howrah Jn", StationCode: "HWH", Time: "16:55", DelayMin: 5, Platform: "9"},
+		{Type: "Arrived", StationName: "Asansol Jn", StationCode: "ASN", Time: "19:12", DelayMin: 15},
+		{Type: "Departed", StationName: "Asansol Jn", StationCode: "ASN", Time: "19:14", DelayMin: 15},
+		{Type: "Arrived", StationName: "Dhanbad Jn", StationCode: "DHN", Time: "20:20", DelayMin: 25},
+	}
+	if len(inst.Events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(inst.Events), len(want), inst.Events)
+	}
+	for i := range want {
+		if inst.Events[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, inst.Events[i], want[i])
+		}
+	}
+}
+
+func TestFetchFromNTESWithoutCSRF(t *testing.T) {
+	s, _ := newFakeNTES(t, fakentes.Options{})
+	ctx := context.Background()
+	if err := s.initNTESSession(ctx); err != nil {
+		t.Fatalf("initNTESSession: %v", err)
+	}
+	s.csrfValue = "stale"
+
+	if _, err := s.fetchFromNTES(ctx, testTrain.Number); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("fetchFromNTES with a bad CSRF token: err = %v, want status 403", err)
+	}
+}
+
+func TestFetchFromNTESSessionExpired(t *testing.T) {
+	s, fake := newFakeNTES(t, fakentes.Options{})
+	ctx := context.Background()
+	if err := s.initNTESSession(ctx); err != nil {
+		t.Fatalf("initNTESSession: %v", err)
+	}
+
+	fake.ExpireSessions()
+	if _, err := s.fetchFromNTES(ctx, testTrain.Number); !errors.Is(err, errSessionExpired) {
+		t.Fatalf("fetchFromNTES after expiry: err = %v, want errSessionExpired", err)
+	}
+
+	if err := s.initNTESSession(ctx); err != nil {
+		t.Fatalf("initNTESSession after expiry: %v", err)
+	}
+	status, err := s.fetchFromNTES(ctx, testTrain.Number)
+	if err != nil {
+		t.Fatalf("fetchFromNTES with a new session: %v", err)
+	}
+	if len(status.Instances) != 1 || len(status.Instances[0].Events) == 0 {
+		t.Errorf("got %+v with a new session, want today's events", status.Instances)
+	}
+}
+
+func TestFetchFromNTESMalformed(t *testing.T) {
+	s, _ := newFakeNTES(t, fakentes.Options{MalformedRate: 1})
+	ctx := context.Background()
+	if err := s.initNTESSession(ctx); err != nil {
+		t.Fatalf("initNTESSession: %v", err)
+	}
+
+	status, err := s.fetchFromNTES(ctx, testTrain.Number)
+	if err != nil {
+		t.Fatalf("fetchFromNTES: %v", err)
+	}
+	for _, inst := range status.Instances {
+		if len(inst.Events) > 0 {
+			t.Errorf("parsed events %+v from a garbled page, want none", inst.Events)
+		}
+	}
+}
+
+func TestParseNTESResponseInstances(t *testing.T) {
+	dep := func(code, hhmm string, delay int) RunningEvent {
+		return RunningEvent{Type: "Departed", StationName: "Stn " + code, StationCode: code, Time: hhmm, DelayMin: delay}
+	}
+	arr := func(code, hhmm string, delay int) RunningEvent {
+		return RunningEvent{Type: "Arrived", StationName: "Stn " + code, StationCode: code, Time: hhmm, DelayMin: delay}
+	}
+
+	tests := []struct {
+		name string
+		page string
+		want []RunningInstance
+	}{
+		{
+			name: "single run without header",
+			page: "<div>Departed from Stn HWH (HWH) at 16:55 12-Oct Delay: 00:05</div>\n" +
+				"<div>Arrived at Stn ASN (ASN) at 19:12 12-Oct Delay: 00:15</div>",
+			want: []RunningInstance{{Events: []RunningEvent{dep("HWH", "16:55", 5), arr("ASN", "19:12", 15)}}},
+		},
+		{
+			name: "two runs",
+			page: "<div>Start Date: 11-Oct-2026</div>\n" +
+				"<div>Arrived at Stn NDLS (NDLS) at 10:40 12-Oct Delay: 00:45 PF 16</div>\n" +
+				"<div>Start Date: 12-Oct-2026</div>\n" +
+				"<div>Departed from Stn HWH (HWH) at 16:50 12-Oct On Time</div>",
+			want: []RunningInstance{
+				{StartDate: "2026-10-11", Events: []RunningEvent{{Type: "Arrived", StationName: "Stn NDLS", StationCode: "NDLS", Time: "10:40", DelayMin: 45, Platform: "16"}}},
+				{StartDate: "2026-10-12", Events: []RunningEvent{dep("HWH", "16:50", 0)}},
+			},
+		},
+		{
+			name: "run not yet started",
+			page: "<div>Start Date: 11-Oct-2026</div>\n" +
+				"<div>Arrived at Stn NDLS (NDLS) at 10:40 12-Oct Delay: 00:45</div>\n" +
+				"<div>Start Date: 12-Oct-2026</div>\n" +
+				"<div>Yet to start from its source</div>",
+			want: []RunningInstance{
+				{StartDate: "2026-10-11", Events: []RunningEvent{arr("NDLS", "10:40", 45)}},
+				{StartDate: "2026-10-12"},
+			},
+		},
+		{
+			name: "unreadable start date",
+			page: "<div>Start Date: 31-Foo-2026</div>\n" +
+				"<div>Departed from Stn HWH (HWH) at 16:50 12-Oct On Time</div>",
+			want: []RunningInstance{{Events: []RunningEvent{dep("HWH", "16:50", 0)}}},
+		},
+		{name: "no events", page: "<div>No running information available.</div>"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseNTESResponse(tt.page)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// The run's jitter applies from the source station on, so every stop of a
+// run is late by the same extra amount.
+func TestFetchFromNTESJitter(t *testing.T) {
+	s, _ := newFakeNTES(t, fakentes.Options{Delay: fakentes.DelayModel{BaseMinutes: 5, PerStopMinutes: 10, JitterMinutes: 30}, Seed: 3})
+	ctx := context.Background()
+	if err := s.initNTESSession(ctx); err != nil {
+		t.Fatalf("initNTESSession: %v", err)
+	}
+
+	status, err := s.fetchFromNTES(ctx, testTrain.Number)
+	if err != nil {
+		t.Fatalf("fetchFromNTES: %v", err)
+	}
+	events := status.Instances[0].Events
+	jitter := events[0].DelayMin - 5
+	if jitter < 0 || jitter > 30 {
+		t.Fatalf("delay at the source = %d, want 5 plus a jitter in [0, 30]", events[0].DelayMin)
+	}
+	for _, ev := range events[1:] {
+		stop := map[string]int{"ASN": 1, "DHN": 2}[ev.StationCode]
+		if want := 5 + 10*stop + jitter; ev.DelayMin != want {
+			t.Errorf("%s %s delay = %d, want %d", ev.Type, ev.StationCode, ev.DelayMin, want)
+		}
+	}
+}
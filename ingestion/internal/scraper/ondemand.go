@@ -0,0 +1,207 @@
+package scraper
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/publisher"
+)
+
+// Refresh reply statuses.
+const (
+	RefreshOK          = "ok"
+	RefreshNoData      = "no_data"
+	RefreshFresh       = "fresh"
+	RefreshRateLimited = "rate_limited"
+	RefreshUnknown     = "unknown_train"
+	RefreshFailed      = "error"
+)
+
+// refreshTracker coalesces concurrent refresh requests for the same train so
+// it is scraped once and every waiting caller gets the reply.
+type refreshTracker struct {
+	mu       sync.Mutex
+	inFlight map[string][]string // train number -> waiting correlation IDs
+	bucket   *tokenBucket
+}
+
+func newRefreshTracker(perMinute int) *refreshTracker {
+	return &refreshTracker{
+		inFlight: make(map[string][]string),
+		bucket:   newTokenBucket(perMinute, time.Minute),
+	}
+}
+
+// join registers a waiter and reports whether the caller should start the
+// scrape (true) or piggyback on one already running (false).
+func (t *refreshTracker) join(trainNumber, correlationID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	waiters, running := t.inFlight[trainNumber]
+	t.inFlight[trainNumber] = append(waiters, correlationID)
+	return !running
+}
+
+func (t *refreshTracker) finish(trainNumber string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	waiters := t.inFlight[trainNumber]
+	delete(t.inFlight, trainNumber)
+	return waiters
+}
+
+// consumeRefreshRequests pops "refresh train X now" requests off the Valkey
+// work queue until ctx is cancelled. Refreshes run under work.
+func (s *Scraper) consumeRefreshRequests(ctx, work context.Context) {
+	if s.config().RefreshQueueKey == "" {
+		return
+	}
+	log.Printf("Listening for on-demand refresh requests on %s", s.config().RefreshQueueKey)
+
+	for {
+		req, err := s.pub.PopRefreshRequest(ctx, s.config().RefreshQueueKey, 5*time.Second)
+		if ctx.Err() != nil {
+			if req != nil {
+				// Popped as shutdown began: answer rather than leave the
+				// caller waiting for a refresh that will not happen.
+				s.replyRefresh(work, req.TrainNumber, []string{req.CorrelationID}, RefreshFailed, "ingestion worker shutting down")
+			}
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to read refresh request: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if req == nil {
+			continue
+		}
+		s.handleRefreshRequest(work, *req)
+	}
+}
+
+func (s *Scraper) handleRefreshRequest(ctx context.Context, req publisher.RefreshRequest) {
+	if s.lastScrapeAge(req.TrainNumber) < time.Duration(s.config().RefreshMinAge)*time.Second {
+		s.replyRefresh(ctx, req.TrainNumber, []string{req.CorrelationID}, RefreshFresh, "")
+		return
+	}
+
+	if !s.refresh.join(req.TrainNumber, req.CorrelationID) {
+		return
+	}
+
+	if !s.refresh.bucket.take() {
+		s.replyRefresh(ctx, req.TrainNumber, s.refresh.finish(req.TrainNumber), RefreshRateLimited, "")
+		return
+	}
+
+	s.inflight.Add(1)
+	go func() {
+		defer s.inflight.Done()
+		status, errMsg := s.refreshTrain(ctx, req.TrainNumber)
+		s.replyRefresh(ctx, req.TrainNumber, s.refresh.finish(req.TrainNumber), status, errMsg)
+	}()
+}
+
+func (s *Scraper) refreshTrain(ctx context.Context, trainNumber string) (string, string) {
+	train, err := s.getTrain(trainNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RefreshUnknown, ""
+	}
+	if err != nil {
+		return RefreshFailed, err.Error()
+	}
+
+	log.Printf("On-demand refresh for %s (%s)", train.Number, train.Name)
+	err = s.scrapeTrain(ctx, train)
+	switch {
+	case errors.Is(err, errNoRunningData), errors.Is(err, errNotRunning):
+		return RefreshNoData, ""
+	case err != nil:
+		return RefreshFailed, err.Error()
+	}
+	return RefreshOK, ""
+}
+
+func (s *Scraper) replyRefresh(ctx context.Context, trainNumber string, correlationIDs []string, status, errMsg string) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, id := range correlationIDs {
+		reply := publisher.RefreshReply{
+			CorrelationID: id,
+			TrainNumber:   trainNumber,
+			Status:        status,
+			Error:         errMsg,
+			Timestamp:     now,
+		}
+		if err := s.pub.PublishRefreshReply(ctx, reply); err != nil {
+			log.Printf("Failed to reply to refresh %s for %s: %v", id, trainNumber, err)
+		}
+	}
+}
+
+func (s *Scraper) lastScrapeAge(trainNumber string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[trainNumber]
+	if !ok || st.LastScrape.IsZero() {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Since(st.LastScrape)
+}
+
+// tokenBucket is a minimal rate limiter: capacity tokens refilled evenly
+// over period.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+func newTokenBucket(capacity int, period time.Duration) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(capacity),
+		tokens:   float64(capacity),
+		rate:     float64(capacity) / period.Seconds(),
+		last:     time.Now(),
+	}
+}
+
+// setCapacity changes the bucket's size and refill rate, keeping the
+// tokens already earned up to the new capacity.
+func (b *tokenBucket) setCapacity(capacity int, period time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.capacity = float64(capacity)
+	b.rate = float64(capacity) / period.Seconds()
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+func (b *tokenBucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
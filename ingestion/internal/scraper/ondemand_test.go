@@ -0,0 +1,27 @@
+package scraper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rail-app/ingestion/internal/config"
+	"github.com/rail-app/ingestion/internal/publisher"
+)
+
+// A request popped just as the worker shuts down is answered, not dropped.
+func TestRefreshRequestAnsweredOnShutdown(t *testing.T) {
+	rec := &recorder{requests: []*publisher.RefreshRequest{{CorrelationID: "c1", TrainNumber: "12301"}}}
+	s := New(config.Defaults(), nil)
+	s.pub = rec
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.consumeRefreshRequests(ctx, context.Background())
+
+	if len(rec.replies) != 1 {
+		t.Fatalf("got %d replies, want 1", len(rec.replies))
+	}
+	if r := rec.replies[0]; r.CorrelationID != "c1" || r.Status != RefreshFailed {
+		t.Errorf("reply = %+v, want %s for c1", r, RefreshFailed)
+	}
+}
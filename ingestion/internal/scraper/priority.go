@@ -0,0 +1,198 @@
+package scraper
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Demand weights: a user travelling today counts for more than one watching
+// a PNR, who counts for more than an open live view.
+const (
+	journeyWeight    = 3
+	watchlistWeight  = 2
+	subscriberWeight = 1
+)
+
+type trainDemand struct {
+	Journeys    int
+	Watchlist   int
+	Subscribers int
+}
+
+// Score ranks trains for scraping. Every train scores at least 1 so idle
+// trains still have a place in the queue.
+func (d trainDemand) Score() int {
+	return 1 + d.Journeys*journeyWeight + d.Watchlist*watchlistWeight + d.Subscribers*subscriberWeight
+}
+
+// trainState is the scraper's per-train bookkeeping.
+type trainState struct {
+	Train      TrainInfo
+	Demand     trainDemand
+	LastScrape time.Time
+	NextDue    time.Time
+	// DueSince is when the train, due but not yet selected, started
+	// waiting for a slot.
+	DueSince time.Time
+
+	// Runs holds each running instance seen, keyed by start date.
+	Runs map[string]*runState
+
+	// Failures counts consecutive scrapes where every source failed, Empty
+	// consecutive scrapes that returned nothing usable. Either backs the
+	// train off; a successful scrape resets both.
+	Failures        int
+	Empty           int
+	LastError       string
+	LastErrorAt     time.Time
+	NotRunningUntil time.Time
+}
+
+// selectTrains ranks every known train by demand and returns the ones to
+// scrape this cycle, most wanted first. Trains anyone is travelling on or
+// watching are due every cycle; idle trains only every ScrapeIdleCycles
+// cycles. At most ScrapeBudget trains are returned.
+func (s *Scraper) selectTrains(ctx context.Context) ([]TrainInfo, error) {
+	trains, demand, err := s.getTrainDemand()
+	if err != nil {
+		return nil, err
+	}
+
+	channels := make([]string, len(trains))
+	for i, t := range trains {
+		channels[i] = fmt.Sprintf("train:live:%s", t.Number)
+	}
+	subs, err := s.pub.ChannelSubscribers(ctx, channels...)
+	if err != nil {
+		log.Printf("Failed to read live subscriber counts: %v", err)
+	}
+
+	return s.rankTrains(trains, demand, subs, time.Now()), nil
+}
+
+// rankTrains picks the trains to scrape at now from those known, given
+// their demand and live subscriber counts, and schedules their next scrape.
+func (s *Scraper) rankTrains(trains []TrainInfo, demand map[string]trainDemand, subs map[string]int64, now time.Time) []TrainInfo {
+	cycle := time.Duration(s.config().PollInterval) * time.Second
+
+	type candidate struct {
+		state    *trainState
+		priority int
+	}
+	var due []candidate
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Trains no longer in the timetable stop reporting their data age.
+	known := make(map[string]bool, len(trains))
+	for _, t := range trains {
+		known[t.Number] = true
+	}
+	for number := range s.states {
+		if !known[number] {
+			dataAge.Delete(number)
+		}
+	}
+
+	for _, t := range trains {
+		st := s.stateLocked(t.Number)
+		st.Train = t
+		st.Demand = demand[t.Number]
+		st.Demand.Subscribers = int(subs[fmt.Sprintf("train:live:%s", t.Number)])
+
+		if now.Before(st.NextDue) || now.Before(st.NotRunningUntil) {
+			continue
+		}
+		// Trains that have waited past their slot gain priority so idle
+		// trains are delayed by busy ones, never shut out for good. A train
+		// never selected has no slot; it waits from when it was first due.
+		if st.DueSince.IsZero() {
+			st.DueSince = st.NextDue
+			if st.DueSince.IsZero() {
+				st.DueSince = now
+			}
+		}
+		overdue := int(now.Sub(st.DueSince) / cycle)
+		due = append(due, candidate{state: st, priority: st.Demand.Score() * (1 + overdue)})
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		if due[i].priority != due[j].priority {
+			return due[i].priority > due[j].priority
+		}
+		return due[i].state.LastScrape.Before(due[j].state.LastScrape)
+	})
+	if s.config().ScrapeBudget > 0 && len(due) > s.config().ScrapeBudget {
+		due = due[:s.config().ScrapeBudget]
+	}
+
+	selected := make([]TrainInfo, 0, len(due))
+	for _, c := range due {
+		every := 1
+		if c.state.Demand.Score() == 1 && s.config().ScrapeIdleCycles > 1 {
+			every = s.config().ScrapeIdleCycles
+		}
+		// Leave a little slack so a train due every cycle is not pushed to
+		// the next one by tick jitter. recordOutcome may push it further.
+		c.state.NextDue = now.Add(time.Duration(every)*cycle - cycle/10)
+		c.state.DueSince = time.Time{}
+		selected = append(selected, c.state.Train)
+	}
+	return selected
+}
+
+func (s *Scraper) stateLocked(trainNumber string) *trainState {
+	st, ok := s.states[trainNumber]
+	if !ok {
+		st = &trainState{Train: TrainInfo{Number: trainNumber}}
+		s.states[trainNumber] = st
+	}
+	return st
+}
+
+// getTrainDemand loads every train with today's journey and watchlist counts.
+func (s *Scraper) getTrainDemand() ([]TrainInfo, map[string]trainDemand, error) {
+	rows, err := s.db.Query(`
+		SELECT t.number, t.name, t.source_station, t.destination_station, t.runs_on,
+			   (SELECT to_char(r.departure_time, 'HH24:MI') FROM train_routes r
+				 WHERE r.train_number = t.number
+				 ORDER BY r.stop_number LIMIT 1),
+			   (SELECT COUNT(*) FROM journeys j
+				 WHERE j.train_number = t.number
+				   AND j.travel_date = CURRENT_DATE
+				   AND j.status IN ('upcoming', 'active')),
+			   (SELECT COUNT(*) FROM pnr_watchlist w
+				 WHERE w.train_number = t.number
+				   AND (w.travel_date IS NULL OR w.travel_date >= CURRENT_DATE))
+		FROM trains t
+		ORDER BY t.number
+	`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var trains []TrainInfo
+	demand := make(map[string]trainDemand)
+	for rows.Next() {
+		var t TrainInfo
+		var d trainDemand
+		var src, dst, runsOn, dep sql.NullString
+		if err := rows.Scan(&t.Number, &t.Name, &src, &dst, &runsOn, &dep, &d.Journeys, &d.Watchlist); err != nil {
+			log.Printf("Failed to scan train: %v", err)
+			continue
+		}
+		t.SourceStation = src.String
+		t.DestStation = dst.String
+		t.RunsOn = runsOn.String
+		t.SourceDeparture = dep.String
+		trains = append(trains, t)
+		demand[t.Number] = d
+	}
+	return trains, demand, rows.Err()
+}
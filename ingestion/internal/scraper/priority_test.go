@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+)
+
+func TestRankTrainsOrdersByDemand(t *testing.T) {
+	s := New(config.Defaults(), nil)
+	trains := []TrainInfo{{Number: "10001"}, {Number: "10002"}, {Number: "10003"}, {Number: "10004"}}
+	demand := map[string]trainDemand{
+		"10001": {},
+		"10002": {Watchlist: 1},
+		"10003": {Journeys: 1},
+	}
+	subs := map[string]int64{"train:live:10004": 4}
+
+	got := s.rankTrains(trains, demand, subs, time.Now())
+	var order []string
+	for _, t := range got {
+		order = append(order, t.Number)
+	}
+	// Scores: 10004 is 5, 10003 is 4, 10002 is 3, the idle 10001 is 1.
+	if want := "[10004 10003 10002 10001]"; fmt.Sprint(order) != want {
+		t.Errorf("order = %v, want %s", order, want)
+	}
+}
+
+// An idle train that has never been scraped must still get a slot when
+// busy trains fill the budget every cycle.
+func TestRankTrainsIdleTrainNotStarved(t *testing.T) {
+	cfg := config.Defaults()
+	s := New(cfg, nil)
+
+	var trains []TrainInfo
+	demand := make(map[string]trainDemand)
+	for i := 0; i < cfg.ScrapeBudget+1; i++ {
+		n := fmt.Sprintf("%05d", 12000+i)
+		trains = append(trains, TrainInfo{Number: n})
+		demand[n] = trainDemand{Journeys: 1}
+	}
+	trains = append(trains, TrainInfo{Number: "99999"})
+
+	now := time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC)
+	cycle := time.Duration(cfg.PollInterval) * time.Second
+	for i := 0; i < 10; i++ {
+		for _, t := range s.rankTrains(trains, demand, nil, now) {
+			if t.Number == "99999" {
+				return
+			}
+		}
+		now = now.Add(cycle)
+	}
+	t.Fatalf("idle train not selected in 10 cycles with %d busy trains and a budget of %d",
+		cfg.ScrapeBudget+1, cfg.ScrapeBudget)
+}
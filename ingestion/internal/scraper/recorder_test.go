@@ -0,0 +1,98 @@
+package scraper
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/publisher"
+)
+
+// recorder is an eventPublisher that keeps what it is given.
+type recorder struct {
+	mu          sync.Mutex
+	disruptions []publisher.ServiceDisruption
+	alerts      []publisher.JourneyDisruption
+	positions   []publisher.TrainPosition
+	boards      []publisher.StationBoard
+	replies     []publisher.RefreshReply
+	requests    []*publisher.RefreshRequest // handed out by PopRefreshRequest
+}
+
+func (r *recorder) PublishTrainPosition(_ context.Context, pos publisher.TrainPosition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.positions = append(r.positions, pos)
+	return nil
+}
+
+func (r *recorder) PublishPlatformChange(context.Context, publisher.PlatformChange) error {
+	return nil
+}
+
+func (r *recorder) PublishDelayEvent(context.Context, publisher.DelayEvent) error { return nil }
+
+func (r *recorder) PublishServiceDisruption(_ context.Context, event publisher.ServiceDisruption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.disruptions = append(r.disruptions, event)
+	return nil
+}
+
+func (r *recorder) PublishJourneyDisruption(_ context.Context, event publisher.JourneyDisruption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, event)
+	return nil
+}
+
+func (r *recorder) PublishStationBoard(_ context.Context, board publisher.StationBoard) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.boards = append(r.boards, board)
+	return nil
+}
+
+func (r *recorder) PublishExpectedPlatform(context.Context, publisher.ExpectedPlatform) error {
+	return nil
+}
+
+func (r *recorder) PublishQuarantinedEvent(context.Context, publisher.QuarantinedEvent) error {
+	return nil
+}
+
+func (r *recorder) ChannelSubscribers(_ context.Context, channels ...string) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
+
+func (r *recorder) PopRefreshRequest(ctx context.Context, _ string, timeout time.Duration) (*publisher.RefreshRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) == 0 {
+		return nil, nil
+	}
+	req := r.requests[0]
+	r.requests = r.requests[1:]
+	return req, nil
+}
+
+func (r *recorder) PublishRefreshReply(_ context.Context, reply publisher.RefreshReply) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replies = append(r.replies, reply)
+	return nil
+}
+
+// refusingDB is a database every query to which fails at once, for code
+// paths that look something up but carry on without it.
+func refusingDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
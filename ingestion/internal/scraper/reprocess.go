@@ -0,0 +1,127 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/archive"
+)
+
+// archivePruneInterval is how often expired archive records are deleted.
+const archivePruneInterval = 6 * time.Hour
+
+// archiveResponse stores a raw upstream body. Archiving is best effort: a
+// failure is logged and never fails the scrape.
+func (s *Scraper) archiveResponse(ctx context.Context, trainNumber, source string, fetchedAt time.Time, body []byte) {
+	s.archiveRecord(ctx, &archive.Record{TrainNumber: trainNumber, Source: source}, fetchedAt, body)
+}
+
+// archiveStationBoard stores a raw station board body, keyed by station.
+func (s *Scraper) archiveStationBoard(ctx context.Context, stationCode string, fetchedAt time.Time, body []byte) {
+	s.archiveRecord(ctx, &archive.Record{StationCode: stationCode, Source: sourceStationBoard}, fetchedAt, body)
+}
+
+func (s *Scraper) archiveRecord(ctx context.Context, rec *archive.Record, fetchedAt time.Time, body []byte) {
+	if s.archive == nil {
+		return
+	}
+	rec.RunDate = fetchedAt.Format("2006-01-02")
+	rec.FetchedAt = fetchedAt.UTC()
+	rec.Body = body
+	if err := s.archive.Put(ctx, rec); err != nil {
+		log.Printf("Failed to archive %s response for %s%s: %v", rec.Source, rec.TrainNumber, rec.StationCode, err)
+	}
+}
+
+// pruneArchive deletes archived responses older than ArchiveRetentionDays
+// until ctx is cancelled.
+func (s *Scraper) pruneArchive(ctx context.Context) {
+	if s.config().ArchiveRetentionDays <= 0 {
+		return
+	}
+	ticker := time.NewTicker(archivePruneInterval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().AddDate(0, 0, -s.config().ArchiveRetentionDays)
+		if n, err := s.archive.Prune(ctx, cutoff); err != nil {
+			log.Printf("Failed to prune response archive: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d archived response(s) older than %s", n, cutoff.Format("2006-01-02"))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reprocess re-parses archived responses fetched in [from, to) and
+// republishes the results, timestamped as of the original fetch. An empty
+// trainNumber reprocesses every train. It returns the number of responses
+// that produced events. The scraper's configuration must have SinkValkey
+// off: historic events belong in Parseable, not on the live channels.
+func (s *Scraper) Reprocess(ctx context.Context, from, to time.Time, trainNumber string) (int, error) {
+	if s.config().SinkValkey {
+		return 0, errors.New("reprocessing must not publish to Valkey; turn SinkValkey off")
+	}
+	if err := s.connect(ctx); err != nil {
+		return 0, err
+	}
+	defer s.db.Close()
+	if s.archive == nil {
+		return 0, fmt.Errorf("archiving is disabled (ARCHIVE_BACKEND=%s)", s.config().ArchiveBackend)
+	}
+
+	records, err := s.archive.List(ctx, from, to, trainNumber)
+	if err != nil {
+		return 0, fmt.Errorf("list archive: %w", err)
+	}
+	log.Printf("Reprocessing %d archived response(s)", len(records))
+
+	trains := make(map[string]TrainInfo)
+	published := 0
+	for _, rec := range records {
+		if ctx.Err() != nil {
+			return published, ctx.Err()
+		}
+
+		if rec.StationCode != "" {
+			// Station boards are archived for inspection only; they carry
+			// no running status to republish.
+			continue
+		}
+
+		train, ok := trains[rec.TrainNumber]
+		if !ok {
+			train, err = s.getTrain(rec.TrainNumber)
+			if err != nil {
+				log.Printf("Skipping %s: %v", rec.TrainNumber, err)
+				continue
+			}
+			trains[rec.TrainNumber] = train
+		}
+
+		status, err := parseResponse(rec.Source, rec.Body)
+		if err != nil {
+			log.Printf("Failed to parse %s response for %s fetched %s (%s): %v",
+				rec.Source, rec.TrainNumber, rec.FetchedAt.Format(time.RFC3339), rec.SHA256[:12], err)
+			continue
+		}
+
+		err = s.handleStatus(ctx, train, status, rec.FetchedAt, true)
+		switch {
+		case errors.Is(err, errNoRunningData), errors.Is(err, errNoValidEvents), errors.Is(err, errNotRunning):
+		case err != nil:
+			log.Printf("Failed to reprocess %s fetched %s: %v", rec.TrainNumber, rec.FetchedAt.Format(time.RFC3339), err)
+		default:
+			published++
+		}
+	}
+	return published, nil
+}
@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"time"
+)
+
+// runRetention is how long a run is remembered after its start date; no
+// train in the timetable runs longer.
+const runRetention = (maxRunDays + 1) * 24 * time.Hour
+
+// runState is the scraper's view of one running instance of a train.
+type runState struct {
+	LastEvent RunningEvent
+	Finished  bool
+	UpdatedAt time.Time
+}
+
+// trackRun records the latest events of a run and reports whether they
+// should be published. A run that has reached its destination is published
+// once and then left alone, so a finished rake stops overwriting the live
+// position of the next day's run.
+func (s *Scraper) trackRun(train TrainInfo, startDate string, events []RunningEvent, route []RouteStop, observedAt time.Time) bool {
+	last := events[len(events)-1]
+	finished := last.Type == "Arrived" && isTerminus(train, route, last.StationCode)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.stateLocked(train.Number)
+	if st.Runs == nil {
+		st.Runs = make(map[string]*runState)
+	}
+	for date, run := range st.Runs {
+		if observedAt.Sub(run.UpdatedAt) > runRetention {
+			delete(st.Runs, date)
+		}
+	}
+
+	run, ok := st.Runs[startDate]
+	if ok && run.Finished && run.LastEvent == last {
+		return false
+	}
+	st.Runs[startDate] = &runState{LastEvent: last, Finished: finished, UpdatedAt: observedAt}
+	return true
+}
+
+// runInProgress reports whether any run of the train seen is still on its
+// way.
+func (s *Scraper) runInProgress(trainNumber string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.stateLocked(trainNumber).Runs {
+		if !run.Finished {
+			return true
+		}
+	}
+	return false
+}
+
+func isTerminus(train TrainInfo, route []RouteStop, code string) bool {
+	if len(route) > 0 {
+		return route[len(route)-1].StationCode == code
+	}
+	return train.DestStation != "" && train.DestStation == code
+}
+
+// runLabel formats a run's start date for log lines.
+func runLabel(startDate string) string {
+	if startDate == "" {
+		return ""
+	}
+	return " [run " + startDate + "]"
+}
@@ -1,8 +1,10 @@
 package scraper
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,14 +12,19 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
+	"github.com/rail-app/ingestion/internal/archive"
 	"github.com/rail-app/ingestion/internal/config"
 	"github.com/rail-app/ingestion/internal/publisher"
+	"github.com/rail-app/ingestion/internal/redact"
 )
 
 type TrainInfo struct {
@@ -25,6 +32,11 @@ type TrainInfo struct {
 	Name          string
 	SourceStation string
 	DestStation   string
+
+	// RunsOn is the weekly running pattern, Monday first ("1111111").
+	// SourceDeparture is the scheduled HH:MM departure from the source.
+	RunsOn          string
+	SourceDeparture string
 }
 
 type RouteStop struct {
@@ -37,6 +49,9 @@ type RouteStop struct {
 	Platform       sql.NullString
 	Latitude       float64
 	Longitude      float64
+	// Located is false for a station stations has no coordinates for,
+	// such as one the timetable sync added.
+	Located bool
 }
 
 type RunningEvent struct {
@@ -48,98 +63,316 @@ type RunningEvent struct {
 	Platform    string
 }
 
+// RunningInstance is one run of a train, identified by the date it left its
+// source. FindRunningInstance lists yesterday's and today's departures
+// separately while both are on the move.
+type RunningInstance struct {
+	StartDate string // YYYY-MM-DD; empty when the upstream does not say
+	Events    []RunningEvent
+}
+
+// Upstream sources, as recorded in RunningStatus.Source and the archive.
+const (
+	sourceNTES  = "ntes"
+	sourceERail = "erail"
+)
+
+// RunningStatus is everything one upstream fetch told us about a train.
+type RunningStatus struct {
+	Source      string
+	Instances   []RunningInstance
+	Disruptions []Disruption
+}
+
+// eventPublisher is what the scraper publishes through and reads refresh
+// requests from: a *publisher.Publisher, or a recorder in tests.
+type eventPublisher interface {
+	PublishTrainPosition(ctx context.Context, pos publisher.TrainPosition) error
+	PublishPlatformChange(ctx context.Context, event publisher.PlatformChange) error
+	PublishDelayEvent(ctx context.Context, event publisher.DelayEvent) error
+	PublishServiceDisruption(ctx context.Context, event publisher.ServiceDisruption) error
+	PublishJourneyDisruption(ctx context.Context, event publisher.JourneyDisruption) error
+	PublishStationBoard(ctx context.Context, board publisher.StationBoard) error
+	PublishExpectedPlatform(ctx context.Context, event publisher.ExpectedPlatform) error
+	PublishQuarantinedEvent(ctx context.Context, event publisher.QuarantinedEvent) error
+	ChannelSubscribers(ctx context.Context, channels ...string) (map[string]int64, error)
+	PopRefreshRequest(ctx context.Context, key string, timeout time.Duration) (*publisher.RefreshRequest, error)
+	PublishRefreshReply(ctx context.Context, reply publisher.RefreshReply) error
+}
+
 type Scraper struct {
-	cfg        *config.Config
-	pub        *publisher.Publisher
+	cfg        atomic.Pointer[config.Config]
+	pub        eventPublisher
 	db         *sql.DB
 	httpClient *http.Client
 	csrfKey    string
 	csrfValue  string
+
+	// scrapeMu serialises upstream access between the polling loop, the
+	// station board poller and on-demand refreshes. It guards the NTES
+	// session and lastDisruption.
+	scrapeMu sync.Mutex
+
+	// lastDisruption holds the signature of the last disruption published
+	// per train and kind so unchanged notices are not re-sent every cycle.
+	lastDisruption map[string]string
+
+	mu     sync.Mutex
+	states map[string]*trainState
+
+	// sessionErr is the outcome of the last NTES session init, kept under
+	// mu for readiness checks, which must not wait on scrapeMu.
+	sessionErr error
+	// startErr is why Start last gave up, also kept under mu.
+	startErr error
+
+	refresh *refreshTracker
+
+	// reload is signalled when SetConfig swaps in a new configuration.
+	reload chan struct{}
+
+	// inflight tracks the goroutines Start must wait for before closing
+	// the database.
+	inflight sync.WaitGroup
+
+	// archive keeps raw upstream bodies; nil when archiving is off.
+	archive archive.Store
 }
 
+var (
+	// errNoRunningData means the upstream answered but had nothing for the train.
+	errNoRunningData = errors.New("no running data")
+	// errNoValidEvents means every event the upstream returned was quarantined.
+	errNoValidEvents = errors.New("all events failed validation")
+	// errNotRunning means the upstream reported today's run fully cancelled.
+	errNotRunning = errors.New("not running today")
+	// errSessionExpired means NTES no longer recognises our session.
+	errSessionExpired = errors.New("NTES session expired")
+	// errNoBoard means a LiveStation answer held no recognisable board.
+	errNoBoard = errors.New("no station board in response")
+)
+
 func New(cfg *config.Config, pub *publisher.Publisher) *Scraper {
 	jar, _ := cookiejar.New(nil)
-	return &Scraper{
-		cfg: cfg,
+	s := &Scraper{
 		pub: pub,
 		httpClient: &http.Client{
 			Timeout: 15 * time.Second,
 			Jar:     jar,
 		},
+		lastDisruption: make(map[string]string),
+		states:         make(map[string]*trainState),
+		refresh:        newRefreshTracker(cfg.RefreshRatePerMin),
+		reload:         make(chan struct{}, 1),
 	}
+	s.cfg.Store(cfg)
+	return s
 }
 
-func (s *Scraper) Start(ctx context.Context) {
-	connStr := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		s.cfg.PostgresHost, s.cfg.PostgresPort,
-		s.cfg.PostgresUser, s.cfg.PostgresPassword, s.cfg.PostgresDB,
-	)
+func (s *Scraper) config() *config.Config {
+	return s.cfg.Load()
+}
 
-	var err error
-	s.db, err = sql.Open("postgres", connStr)
+// SetConfig switches the scraper to a reloaded configuration. Poll
+// intervals, budgets, rate limits and station lists take effect from the
+// next cycle.
+func (s *Scraper) SetConfig(cfg *config.Config) {
+	s.cfg.Store(cfg)
+	s.refresh.bucket.setCapacity(cfg.RefreshRatePerMin, time.Minute)
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the scraper until ctx is cancelled. It then stops scheduling
+// and waits for the scrapes and refreshes already under way, which run
+// under work; the caller cancels work when its drain deadline passes.
+// Start may be called again once it returns, keeping per-train state.
+func (s *Scraper) Start(ctx, work context.Context) {
+	err := s.connect(ctx)
+	s.mu.Lock()
+	s.startErr = err
+	s.mu.Unlock()
 	if err != nil {
-		log.Printf("Failed to connect to database: %v", err)
+		log.Printf("Scraper failed to start: %v", err)
 		return
 	}
 	defer s.db.Close()
 
-	for i := 0; i < 30; i++ {
-		if err := s.db.Ping(); err == nil {
-			break
-		}
-		log.Println("Waiting for database...")
-		time.Sleep(2 * time.Second)
+	if s.archive != nil {
+		s.inflight.Add(1)
+		go func() {
+			defer s.inflight.Done()
+			s.pruneArchive(ctx)
+		}()
 	}
 
 	log.Println("Real data scraper started")
-	ticker := time.NewTicker(time.Duration(s.cfg.PollInterval) * time.Second)
+	ticker := time.NewTicker(time.Duration(s.config().PollInterval) * time.Second)
 	defer ticker.Stop()
 
-	s.scrapeAll(ctx)
+	// The board ticker only runs while there are stations to poll at a
+	// positive interval; a nil channel never fires.
+	var boardTicker *time.Ticker
+	var boardC <-chan time.Time
+	setBoardTicker := func(cfg *config.Config) {
+		if boardTicker != nil {
+			boardTicker.Stop()
+			boardTicker, boardC = nil, nil
+		}
+		if cfg.StationBoardInterval > 0 && len(cfg.StationBoardStations) > 0 {
+			boardTicker = time.NewTicker(time.Duration(cfg.StationBoardInterval) * time.Second)
+			boardC = boardTicker.C
+		}
+	}
+	setBoardTicker(s.config())
+	defer func() {
+		if boardTicker != nil {
+			boardTicker.Stop()
+		}
+	}()
+
+	s.inflight.Add(1)
+	go func() {
+		defer s.inflight.Done()
+		s.consumeRefreshRequests(ctx, work)
+	}()
+
+	s.scrapeAll(ctx, work)
+	s.pollStationBoards(ctx, work)
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Scraper stopping...")
+			log.Println("Scraper stopping, waiting for in-flight work...")
+			s.inflight.Wait()
 			return
 		case <-ticker.C:
-			s.scrapeAll(ctx)
+			s.scrapeAll(ctx, work)
+		case <-boardC:
+			s.pollStationBoards(ctx, work)
+		case <-s.reload:
+			cfg := s.config()
+			ticker.Reset(time.Duration(cfg.PollInterval) * time.Second)
+			setBoardTicker(cfg)
 		}
 	}
 }
 
-func (s *Scraper) scrapeAll(ctx context.Context) {
-	trains, err := s.getActiveTrains()
+// connect opens the database and the response archive. It waits up to a
+// minute for the database, or until ctx is cancelled.
+func (s *Scraper) connect(ctx context.Context) error {
+	var err error
+	s.db, err = sql.Open("postgres", s.config().PostgresDSN())
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < 30; i++ {
+		if err := s.db.PingContext(ctx); err == nil {
+			break
+		}
+		log.Println("Waiting for database...")
+		select {
+		case <-ctx.Done():
+			s.db.Close()
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	s.archive, err = archive.Open(s.config(), s.db)
+	if err != nil {
+		s.db.Close()
+		return fmt.Errorf("open archive: %w", err)
+	}
+	return nil
+}
+
+// scrapeAll scrapes the trains due this cycle. It stops starting new
+// scrapes once ctx is cancelled; the scrapes themselves run under work.
+func (s *Scraper) scrapeAll(ctx, work context.Context) {
+	trains, err := s.selectTrains(work)
 	if err != nil {
 		log.Printf("Failed to get active trains: %v", err)
 		return
 	}
+	if len(trains) == 0 {
+		return
+	}
+	start := time.Now()
+	defer observeCycle(loopTrains, start, s.config().PollInterval)
 
 	// Refresh NTES session before each batch
-	if err := s.initNTESSession(ctx); err != nil {
+	s.scrapeMu.Lock()
+	err = s.refreshNTESSession(work)
+	s.scrapeMu.Unlock()
+	if err != nil {
 		log.Printf("NTES session init failed: %v, will try eRail fallback", err)
 	}
 
 	log.Printf("Scraping real data for %d trains...", len(trains))
 
 	for _, train := range trains {
-		select {
-		case <-ctx.Done():
+		if ctx.Err() != nil {
 			return
-		default:
-			s.scrapeTrain(ctx, train)
-			// Rate limit: 1 request per 2 seconds to be respectful
-			time.Sleep(2 * time.Second)
 		}
+		s.scrapeTrain(work, train)
+		if !s.pace(ctx) {
+			return
+		}
+	}
+}
+
+// pace waits ScrapeRequestDelay between upstream requests, to be
+// respectful. It reports false if ctx is cancelled first.
+func (s *Scraper) pace(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(time.Duration(s.config().ScrapeRequestDelay) * time.Second):
+		return true
 	}
 }
 
 // ---- NTES Session Management ----
 
+// Started reports why the last call to Start gave up without scraping. It
+// is nil before the first call and while Start runs.
+func (s *Scraper) Started() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.startErr != nil {
+		return fmt.Errorf("scraper not started: %w", s.startErr)
+	}
+	return nil
+}
+
+// NTESSession reports whether the last attempt to open an NTES session
+// failed. It is nil before the first attempt.
+func (s *Scraper) NTESSession() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessionErr != nil {
+		return fmt.Errorf("NTES session: %w", s.sessionErr)
+	}
+	return nil
+}
+
+// refreshNTESSession opens a new NTES session and records the outcome for
+// NTESSession. The caller holds scrapeMu.
+func (s *Scraper) refreshNTESSession(ctx context.Context) error {
+	err := s.initNTESSession(ctx)
+	s.mu.Lock()
+	s.sessionErr = err
+	s.mu.Unlock()
+	return err
+}
+
 func (s *Scraper) initNTESSession(ctx context.Context) error {
 	// Step 1: Bootstrap session
-	req, err := http.NewRequestWithContext(ctx, "GET", s.cfg.NTESBaseURL+"/mntes/", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", s.config().NTESBaseURL+"/mntes/", nil)
 	if err != nil {
 		return fmt.Errorf("create bootstrap request: %w", err)
 	}
@@ -152,14 +385,14 @@ func (s *Scraper) initNTESSession(ctx context.Context) error {
 
 	// Step 2: Get CSRF token
 	ts := time.Now().UnixMilli()
-	csrfURL := fmt.Sprintf("%s/mntes/GetCSRFToken?t=%d", s.cfg.NTESBaseURL, ts)
+	csrfURL := fmt.Sprintf("%s/mntes/GetCSRFToken?t=%d", s.config().NTESBaseURL, ts)
 	req, err = http.NewRequestWithContext(ctx, "GET", csrfURL, nil)
 	if err != nil {
 		return fmt.Errorf("create csrf request: %w", err)
 	}
 	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
-	req.Header.Set("Referer", s.cfg.NTESBaseURL+"/mntes/")
+	req.Header.Set("Referer", s.config().NTESBaseURL+"/mntes/")
 
 	resp, err = s.httpClient.Do(req)
 	if err != nil {
@@ -181,44 +414,148 @@ func (s *Scraper) initNTESSession(ctx context.Context) error {
 
 	s.csrfKey = string(matches[1])
 	s.csrfValue = string(matches[2])
-	log.Printf("NTES session initialized (CSRF key: %s)", s.csrfKey)
+	redact.Set("ntes-csrf", s.csrfKey, s.csrfValue)
+	log.Println("NTES session initialized")
 	return nil
 }
 
 // ---- Train Scraping ----
 
-func (s *Scraper) scrapeTrain(ctx context.Context, train TrainInfo) {
+func (s *Scraper) scrapeTrain(ctx context.Context, train TrainInfo) error {
+	s.scrapeMu.Lock()
+	defer s.scrapeMu.Unlock()
+
+	trainScrapes.Inc()
+
 	// Try NTES first
-	events, err := s.fetchFromNTES(ctx, train.Number)
+	start := time.Now()
+	status, err := s.fetchFromNTES(ctx, train.Number)
+	if errors.Is(err, errSessionExpired) {
+		// NTES drops sessions when it restarts; open a new one and retry
+		// once rather than waiting for the next cycle.
+		if err = s.refreshNTESSession(ctx); err == nil {
+			status, err = s.fetchFromNTES(ctx, train.Number)
+		}
+	}
+	observeFetch(sourceNTES, start, err)
 	if err != nil {
 		log.Printf("NTES failed for %s: %v, trying eRail...", train.Number, err)
+		scrapeFallbacks.Inc()
 		// Fallback to eRail
-		events, err = s.fetchFromERail(ctx, train.Number)
+		start = time.Now()
+		status, err = s.fetchFromERail(ctx, train.Number)
+		observeFetch(sourceERail, start, err)
 		if err != nil {
 			log.Printf("eRail also failed for %s: %v", train.Number, err)
-			return
+			s.recordOutcome(train, err, time.Now())
+			return err
 		}
 	}
 
-	if len(events) == 0 {
-		log.Printf("No running data for %s (%s) — train may not be running today", train.Number, train.Name)
-		return
+	now := time.Now()
+	s.mu.Lock()
+	s.stateLocked(train.Number).LastScrape = now
+	s.mu.Unlock()
+
+	err = s.handleStatus(ctx, train, status, now, false)
+	if errors.Is(err, errNoRunningData) {
+		parseEmpty.With(status.Source).Inc()
 	}
+	s.recordOutcome(train, err, now)
+	return err
+}
 
-	// Get route info for GPS coordinates
+// handleStatus turns one upstream answer into published events. It is shared
+// by live scraping and reprocessing of archived responses; observedAt is when
+// the upstream produced the answer, and reprocess is set for archived ones.
+func (s *Scraper) handleStatus(ctx context.Context, train TrainInfo, status *RunningStatus, observedAt time.Time, reprocess bool) error {
+	// Get route info for GPS coordinates and disruption scope
 	route, _ := s.getTrainRoute(train.Number)
+
+	disruptions := collectDisruptions(status, route)
+	s.publishDisruptions(ctx, train, disruptions, observedAt, reprocess)
+	if status.Source == sourceNTES {
+		// eRail carries no notices, so its silence proves nothing.
+		s.forgetDisruptions(train, disruptions)
+	}
+
 	stationCoords := make(map[string][2]float64)
 	for _, stop := range route {
-		stationCoords[stop.StationCode] = [2]float64{stop.Latitude, stop.Longitude}
+		if stop.Located {
+			stationCoords[stop.StationCode] = [2]float64{stop.Latitude, stop.Longitude}
+		}
+	}
+	// A diverted train reports stations its route does not list; those
+	// with known coordinates can still place it.
+	offRoute := make(map[string]bool)
+	for _, inst := range status.Instances {
+		if d := detectOffRoute(inst.Events, route); d != nil {
+			for _, code := range d.AffectedStations {
+				offRoute[code] = false
+			}
+		}
+	}
+	if len(offRoute) > 0 {
+		coords, err := s.getStationCoords(offRoute)
+		if err != nil {
+			log.Printf("Failed to look up off-route stations for %s: %v", train.Number, err)
+		}
+		for code, c := range coords {
+			stationCoords[code] = c
+			offRoute[code] = true
+		}
 	}
 
-	// Process events and publish
-	s.processEvents(ctx, train, events, stationCoords)
+	// Each instance is validated and published as its own run, oldest
+	// first, so a rake still finishing yesterday's run never moves today's.
+	sort.SliceStable(status.Instances, func(i, j int) bool {
+		return status.Instances[i].StartDate < status.Instances[j].StartDate
+	})
+
+	published, anyEvents := 0, false
+	for _, inst := range status.Instances {
+		if len(inst.Events) == 0 {
+			continue
+		}
+		anyEvents = true
+
+		events, rejected := validateEvents(inst.Events, route, offRoute, s.config().ValidationMaxDelay)
+		if len(rejected) > 0 {
+			s.quarantineEvents(ctx, train, status.Source, rejected)
+		}
+		if len(events) == 0 {
+			continue
+		}
+		published++
+
+		if !s.trackRun(train, inst.StartDate, events, route, observedAt) {
+			continue
+		}
+		s.processEvents(ctx, train, inst.StartDate, events, stationCoords, observedAt)
+	}
+	if published > 0 && !s.runInProgress(train.Number) {
+		// Every run seen has reached its destination; the train's data age
+		// would only grow until the next one departs.
+		dataAge.Delete(train.Number)
+	}
+
+	switch {
+	case !anyEvents && fullyCancelled(disruptions):
+		return errNotRunning
+	case !anyEvents:
+		if len(disruptions) == 0 {
+			log.Printf("No running data for %s (%s) — train may not be running today", train.Number, train.Name)
+		}
+		return errNoRunningData
+	case published == 0:
+		return errNoValidEvents
+	}
+	return nil
 }
 
 // ---- NTES Fetcher ----
 
-func (s *Scraper) fetchFromNTES(ctx context.Context, trainNumber string) ([]RunningEvent, error) {
+func (s *Scraper) fetchFromNTES(ctx context.Context, trainNumber string) (*RunningStatus, error) {
 	if s.csrfKey == "" {
 		return nil, fmt.Errorf("no CSRF token available")
 	}
@@ -228,14 +565,14 @@ func (s *Scraper) fetchFromNTES(ctx context.Context, trainNumber string) ([]Runn
 
 	ntesURL := fmt.Sprintf(
 		"%s/mntes/tr?opt=TrainRunning&subOpt=FindRunningInstance&refDate=%s",
-		s.cfg.NTESBaseURL, refDate,
+		s.config().NTESBaseURL, refDate,
 	)
 
 	formData := url.Values{
-		"lan":      {"en"},
-		"jDate":    {refDate},
-		"trainNo":  {trainNumber},
-		s.csrfKey:  {s.csrfValue},
+		"lan":     {"en"},
+		"jDate":   {refDate},
+		"trainNo": {trainNumber},
+		s.csrfKey: {s.csrfValue},
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", ntesURL, strings.NewReader(formData.Encode()))
@@ -246,8 +583,8 @@ func (s *Scraper) fetchFromNTES(ctx context.Context, trainNumber string) ([]Runn
 	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
-	req.Header.Set("Referer", s.cfg.NTESBaseURL+"/mntes/")
-	req.Header.Set("Origin", s.cfg.NTESBaseURL)
+	req.Header.Set("Referer", s.config().NTESBaseURL+"/mntes/")
+	req.Header.Set("Origin", s.config().NTESBaseURL)
 	req.Header.Set("Accept", "*/*")
 	req.Header.Set("Cache-Control", "no-cache")
 
@@ -266,11 +603,56 @@ func (s *Scraper) fetchFromNTES(ctx context.Context, trainNumber string) ([]Runn
 		return nil, fmt.Errorf("read body: %w", err)
 	}
 
-	return parseNTESResponse(string(body))
+	if bytes.Contains(body, []byte("Session Expired")) {
+		return nil, errSessionExpired
+	}
+
+	s.archiveResponse(ctx, trainNumber, sourceNTES, now, body)
+	return parseResponse(sourceNTES, body)
+}
+
+// parseResponse parses a raw upstream body from the named source.
+func parseResponse(source string, body []byte) (*RunningStatus, error) {
+	switch source {
+	case sourceNTES:
+		instances, err := parseNTESResponse(string(body))
+		if err != nil {
+			return nil, err
+		}
+		return &RunningStatus{
+			Source:      sourceNTES,
+			Instances:   instances,
+			Disruptions: parseNTESNotices(string(body)),
+		}, nil
+	case sourceERail:
+		events, err := parseERailResponse(string(body))
+		if err != nil {
+			return nil, err
+		}
+		// eRail only reports the current run and does not say which it is.
+		return &RunningStatus{Source: sourceERail, Instances: []RunningInstance{{Events: events}}}, nil
+	default:
+		return nil, fmt.Errorf("unknown source %q", source)
+	}
 }
 
-func parseNTESResponse(html string) ([]RunningEvent, error) {
+// startDateRe matches the header NTES puts above each running instance.
+var startDateRe = regexp.MustCompile(`Start\s+Date:\s*(\d{2}-\w{3}-\d{4})`)
+
+// parseNTESResponse splits a FindRunningInstance page into its running
+// instances. Events before any "Start Date" header form an instance with no
+// start date.
+func parseNTESResponse(html string) ([]RunningInstance, error) {
+	var instances []RunningInstance
 	var events []RunningEvent
+	startDate := ""
+
+	flush := func() {
+		if len(events) > 0 || startDate != "" {
+			instances = append(instances, RunningInstance{StartDate: startDate, Events: events})
+		}
+		events = nil
+	}
 
 	// NTES returns HTML with lines containing station events
 	// Patterns:
@@ -286,6 +668,15 @@ func parseNTESResponse(html string) ([]RunningEvent, error) {
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 
+		if m := startDateRe.FindStringSubmatch(line); len(m) > 0 {
+			flush()
+			startDate = ""
+			if d, err := time.Parse("02-Jan-2006", m[1]); err == nil {
+				startDate = d.Format("2006-01-02")
+			}
+			continue
+		}
+
 		if matches := departedRe.FindStringSubmatch(line); len(matches) > 0 {
 			ev := RunningEvent{
 				Type:        "Departed",
@@ -322,13 +713,14 @@ func parseNTESResponse(html string) ([]RunningEvent, error) {
 			events = append(events, ev)
 		}
 	}
+	flush()
 
-	return events, nil
+	return instances, nil
 }
 
 // ---- eRail Fallback Fetcher ----
 
-func (s *Scraper) fetchFromERail(ctx context.Context, trainNumber string) ([]RunningEvent, error) {
+func (s *Scraper) fetchFromERail(ctx context.Context, trainNumber string) (*RunningStatus, error) {
 	erailURL := fmt.Sprintf(
 		"https://erail.in/data.aspx?Action=TRAINROUTE&Password=2012&Data1=%s&Data2=0&Cache=true",
 		trainNumber,
@@ -351,7 +743,8 @@ func (s *Scraper) fetchFromERail(ctx context.Context, trainNumber string) ([]Run
 		return nil, fmt.Errorf("read erail body: %w", err)
 	}
 
-	return parseERailResponse(string(body))
+	s.archiveResponse(ctx, trainNumber, sourceERail, time.Now(), body)
+	return parseResponse(sourceERail, body)
 }
 
 func parseERailResponse(data string) ([]RunningEvent, error) {
@@ -414,28 +807,32 @@ func parseERailResponse(data string) ([]RunningEvent, error) {
 
 // ---- Event Processing & Publishing ----
 
-func (s *Scraper) processEvents(ctx context.Context, train TrainInfo, events []RunningEvent, stationCoords map[string][2]float64) {
+func (s *Scraper) processEvents(ctx context.Context, train TrainInfo, runDate string, events []RunningEvent, stationCoords map[string][2]float64, observedAt time.Time) {
 	if len(events) == 0 {
 		return
 	}
 
 	// The last event tells us the current position
 	lastEvent := events[len(events)-1]
-	now := time.Now().UTC()
+	now := observedAt.UTC()
+	dataAge.With(train.Number).Observe(observedAt)
 
-	// Get coordinates for the station
-	lat, lng := 0.0, 0.0
-	if coords, ok := stationCoords[lastEvent.StationCode]; ok {
-		lat = coords[0]
-		lng = coords[1]
-	}
+	// Get coordinates for the station. Without them there is no position
+	// to publish, but the platform and delay events below still stand.
+	coords, located := stationCoords[lastEvent.StationCode]
+	lat, lng := coords[0], coords[1]
 
-	// Determine next station from route
+	// Determine next station from route. A train last seen off its route
+	// is diverted, and where it rejoins the route is not known.
 	nextStation := ""
 	route, _ := s.getTrainRoute(train.Number)
+	diverted := len(route) > 0
 	for i, stop := range route {
-		if stop.StationCode == lastEvent.StationCode && i+1 < len(route) {
-			nextStation = route[i+1].StationCode
+		if stop.StationCode == lastEvent.StationCode {
+			diverted = false
+			if i+1 < len(route) {
+				nextStation = route[i+1].StationCode
+			}
 			break
 		}
 	}
@@ -453,14 +850,18 @@ func (s *Scraper) processEvents(ctx context.Context, train TrainInfo, events []R
 		CurrentStation: lastEvent.StationCode,
 		NextStation:    nextStation,
 		ETANext:        etaNext,
+		RunStartDate:   runDate,
+		Diverted:       diverted,
 		Timestamp:      now.Format(time.RFC3339),
 	}
 
-	if err := s.pub.PublishTrainPosition(ctx, pos); err != nil {
+	if !located {
+		log.Printf("No coordinates for %s, not publishing a position for %s", lastEvent.StationCode, train.Number)
+	} else if err := s.pub.PublishTrainPosition(ctx, pos); err != nil {
 		log.Printf("Failed to publish position for %s: %v", train.Number, err)
 	} else {
-		log.Printf("[REAL] %s (%s): %s at %s, delay=%dm, speed≈%dkm/h",
-			train.Number, train.Name, lastEvent.Type, lastEvent.StationCode,
+		log.Printf("[REAL] %s (%s)%s: %s at %s, delay=%dm, speed≈%dkm/h",
+			train.Number, train.Name, runLabel(runDate), lastEvent.Type, lastEvent.StationCode,
 			lastEvent.DelayMin, pos.SpeedKmph)
 	}
 
@@ -472,6 +873,7 @@ func (s *Scraper) processEvents(ctx context.Context, train TrainInfo, events []R
 				PlatformNumber: ev.Platform,
 				TrainNumber:    train.Number,
 				EventType:      strings.ToLower(ev.Type),
+				RunStartDate:   runDate,
 				Timestamp:      now.Format(time.RFC3339),
 			}
 			s.pub.PublishPlatformChange(ctx, platEvent)
@@ -487,6 +889,7 @@ func (s *Scraper) processEvents(ctx context.Context, train TrainInfo, events []R
 			ActualTime:    now.Format("15:04"),
 			DelayMinutes:  lastEvent.DelayMin,
 			Cause:         "Reported by NTES",
+			RunStartDate:  runDate,
 			Timestamp:     now.Format(time.RFC3339),
 		}
 		s.pub.PublishDelayEvent(ctx, delayEv)
@@ -506,37 +909,33 @@ func estimateSpeed(event RunningEvent) int {
 
 // ---- Database Queries ----
 
-func (s *Scraper) getActiveTrains() ([]TrainInfo, error) {
-	rows, err := s.db.Query(`
-		SELECT number, name, source_station, destination_station
-		FROM trains
-		LIMIT 50
-	`)
+func (s *Scraper) getTrain(trainNumber string) (TrainInfo, error) {
+	var t TrainInfo
+	var src, dst, runsOn, dep sql.NullString
+	err := s.db.QueryRow(`
+		SELECT t.number, t.name, t.source_station, t.destination_station, t.runs_on,
+			   (SELECT to_char(r.departure_time, 'HH24:MI') FROM train_routes r
+				 WHERE r.train_number = t.number
+				 ORDER BY r.stop_number LIMIT 1)
+		FROM trains t
+		WHERE t.number = $1
+	`, trainNumber).Scan(&t.Number, &t.Name, &src, &dst, &runsOn, &dep)
 	if err != nil {
-		return nil, err
+		return TrainInfo{}, err
 	}
-	defer rows.Close()
-
-	var trains []TrainInfo
-	for rows.Next() {
-		var t TrainInfo
-		var src, dst sql.NullString
-		if err := rows.Scan(&t.Number, &t.Name, &src, &dst); err != nil {
-			log.Printf("Failed to scan train: %v", err)
-			continue
-		}
-		t.SourceStation = src.String
-		t.DestStation = dst.String
-		trains = append(trains, t)
-	}
-	return trains, nil
+	t.SourceStation = src.String
+	t.DestStation = dst.String
+	t.RunsOn = runsOn.String
+	t.SourceDeparture = dep.String
+	return t, nil
 }
 
 func (s *Scraper) getTrainRoute(trainNumber string) ([]RouteStop, error) {
 	rows, err := s.db.Query(`
 		SELECT tr.station_code, tr.stop_number, tr.arrival_time, tr.departure_time,
 			   tr.distance_from_source, tr.day_number, tr.platform,
-			   COALESCE(s.latitude, 0), COALESCE(s.longitude, 0)
+			   COALESCE(s.latitude, 0), COALESCE(s.longitude, 0),
+			   s.latitude IS NOT NULL AND s.longitude IS NOT NULL
 		FROM train_routes tr
 		JOIN stations s ON s.code = tr.station_code
 		WHERE tr.train_number = $1
@@ -554,7 +953,7 @@ func (s *Scraper) getTrainRoute(trainNumber string) ([]RouteStop, error) {
 			&stop.StationCode, &stop.StopNumber,
 			&stop.ArrivalTime, &stop.DepartureTime,
 			&stop.DistFromSource, &stop.DayNumber, &stop.Platform,
-			&stop.Latitude, &stop.Longitude,
+			&stop.Latitude, &stop.Longitude, &stop.Located,
 		); err != nil {
 			log.Printf("Failed to scan route stop: %v", err)
 			continue
@@ -564,6 +963,69 @@ func (s *Scraper) getTrainRoute(trainNumber string) ([]RouteStop, error) {
 	return stops, nil
 }
 
+// getStationCoords returns the coordinates of those of codes that stations
+// has a position for.
+func (s *Scraper) getStationCoords(codes map[string]bool) (map[string][2]float64, error) {
+	list := make([]string, 0, len(codes))
+	for code := range codes {
+		list = append(list, code)
+	}
+	rows, err := s.db.Query(`
+		SELECT code, latitude, longitude
+		FROM stations
+		WHERE code = ANY($1) AND latitude IS NOT NULL AND longitude IS NOT NULL
+	`, pq.Array(list))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coords := make(map[string][2]float64)
+	for rows.Next() {
+		var code string
+		var lat, lng float64
+		if err := rows.Scan(&code, &lat, &lng); err != nil {
+			return nil, err
+		}
+		coords[code] = [2]float64{lat, lng}
+	}
+	return coords, rows.Err()
+}
+
+type journeyRef struct {
+	ID     string
+	UserID string
+	PNR    string
+}
+
+// getTravellingJourneys returns today's upcoming and active journeys on a train.
+func (s *Scraper) getTravellingJourneys(trainNumber string) ([]journeyRef, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, COALESCE(pnr, '')
+		FROM journeys
+		WHERE train_number = $1
+		  AND travel_date = CURRENT_DATE
+		  AND status IN ('upcoming', 'active')
+	`, trainNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var journeys []journeyRef
+	for rows.Next() {
+		var j journeyRef
+		var userID sql.NullString
+		if err := rows.Scan(&j.ID, &userID, &j.PNR); err != nil {
+			log.Printf("Failed to scan journey: %v", err)
+			continue
+		}
+		j.UserID = userID.String
+		journeys = append(journeys, j)
+	}
+	return journeys, nil
+}
+
 // ---- Constants ----
 
 const userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
@@ -0,0 +1,31 @@
+package scraper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/config"
+)
+
+// A station the timetable sync added has no coordinates yet; the train is
+// not published at 0,0 there.
+func TestNoPositionWithoutCoordinates(t *testing.T) {
+	rec := &recorder{}
+	s := New(config.Defaults(), nil)
+	s.pub = rec
+	s.db = refusingDB(t)
+	train := TrainInfo{Number: "12301", Name: "Howrah Rajdhani"}
+	events := []RunningEvent{{Type: "Departed", StationCode: "NEW", Time: "10:00"}}
+
+	s.processEvents(context.Background(), train, "2026-10-19", events, map[string][2]float64{}, time.Now())
+	if len(rec.positions) != 0 {
+		t.Errorf("published %+v without coordinates, want nothing", rec.positions)
+	}
+
+	coords := map[string][2]float64{"NEW": {22.58, 88.34}}
+	s.processEvents(context.Background(), train, "2026-10-19", events, coords, time.Now())
+	if len(rec.positions) != 1 || rec.positions[0].Latitude != 22.58 {
+		t.Errorf("published %+v with coordinates, want one position at them", rec.positions)
+	}
+}
@@ -0,0 +1,226 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/publisher"
+)
+
+type BoardEntry struct {
+	TrainNumber        string
+	TrainName          string
+	ScheduledArrival   string
+	ExpectedArrival    string
+	ScheduledDeparture string
+	ExpectedDeparture  string
+	DelayMin           int
+	Platform           string
+}
+
+// NTES lists one train per line:
+//
+//	"12301 Howrah Rajdhani Express | Sch Arr: 09:55 | Exp Arr: 10:20 | Sch Dep: -- | Exp Dep: -- | Delay: 00:25 | PF 16"
+var boardLineRe = regexp.MustCompile(
+	`(\d{5})\s+(.+?)\s*\|\s*Sch Arr:\s*(\d{2}:\d{2}|--)\s*\|\s*Exp Arr:\s*(\d{2}:\d{2}|--)` +
+		`\s*\|\s*Sch Dep:\s*(\d{2}:\d{2}|--)\s*\|\s*Exp Dep:\s*(\d{2}:\d{2}|--)` +
+		`(?:\s*\|\s*Delay:\s*(\d{2}):(\d{2}))?(?:\s*\|\s*(?:PF|Platform)\s*#?\s*(\d+))?`,
+)
+
+// pollStationBoards fetches the live board for every configured station and
+// publishes a snapshot plus per-train expected platforms. Like scrapeAll it
+// stops at the next station once ctx is cancelled.
+func (s *Scraper) pollStationBoards(ctx, work context.Context) {
+	if len(s.config().StationBoardStations) == 0 {
+		return
+	}
+	start := time.Now()
+	defer observeCycle(loopStationBoards, start, s.config().StationBoardInterval)
+
+	// scrapeAll opens a session for each batch of trains, but a deployment
+	// may poll boards without selecting any trains.
+	s.scrapeMu.Lock()
+	if s.csrfKey == "" {
+		if err := s.refreshNTESSession(work); err != nil {
+			log.Printf("NTES session init failed: %v", err)
+		}
+	}
+	s.scrapeMu.Unlock()
+
+	for _, code := range s.config().StationBoardStations {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		s.scrapeMu.Lock()
+		fetchStart := time.Now()
+		entries, err := s.fetchStationBoard(work, code, s.config().StationBoardHours)
+		if errors.Is(err, errSessionExpired) {
+			// As in scrapeTrain: open a new session and retry once.
+			if err = s.refreshNTESSession(work); err == nil {
+				entries, err = s.fetchStationBoard(work, code, s.config().StationBoardHours)
+			}
+		}
+		observeFetch(sourceStationBoard, fetchStart, err)
+		s.scrapeMu.Unlock()
+		if err != nil {
+			log.Printf("Station board fetch failed for %s: %v", code, err)
+		} else {
+			s.publishStationBoard(work, code, entries)
+		}
+
+		if !s.pace(ctx) {
+			return
+		}
+	}
+}
+
+func (s *Scraper) fetchStationBoard(ctx context.Context, stationCode string, hours int) ([]BoardEntry, error) {
+	if s.csrfKey == "" {
+		return nil, fmt.Errorf("no CSRF token available")
+	}
+
+	ntesURL := fmt.Sprintf("%s/mntes/q?opt=LiveStation&subOpt=show", s.config().NTESBaseURL)
+	formData := url.Values{
+		"lan":     {"en"},
+		"stnCode": {stationCode},
+		"hrs":     {strconv.Itoa(hours)},
+		s.csrfKey: {s.csrfValue},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ntesURL, strings.NewReader(formData.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("Referer", s.config().NTESBaseURL+"/mntes/")
+	req.Header.Set("Origin", s.config().NTESBaseURL)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("NTES returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+
+	if bytes.Contains(body, []byte("Session Expired")) {
+		return nil, errSessionExpired
+	}
+
+	s.archiveStationBoard(ctx, stationCode, time.Now(), body)
+	return parseStationBoard(string(body))
+}
+
+// parseStationBoard parses a LiveStation page. A page without the board's
+// heading, or one listing no trains without saying the window is empty, is
+// an error rather than an empty board, so a garbled answer never wipes the
+// published one.
+func parseStationBoard(html string) ([]BoardEntry, error) {
+	if !strings.Contains(html, "Live Station") {
+		return nil, errNoBoard
+	}
+	var entries []BoardEntry
+	for _, line := range strings.Split(html, "\n") {
+		m := boardLineRe.FindStringSubmatch(stripTags(line))
+		if len(m) == 0 {
+			continue
+		}
+		e := BoardEntry{
+			TrainNumber:        m[1],
+			TrainName:          strings.TrimSpace(m[2]),
+			ScheduledArrival:   dashToEmpty(m[3]),
+			ExpectedArrival:    dashToEmpty(m[4]),
+			ScheduledDeparture: dashToEmpty(m[5]),
+			ExpectedDeparture:  dashToEmpty(m[6]),
+			Platform:           m[9],
+		}
+		if m[7] != "" && m[8] != "" {
+			hours, _ := strconv.Atoi(m[7])
+			mins, _ := strconv.Atoi(m[8])
+			e.DelayMin = hours*60 + mins
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 && !strings.Contains(html, "No trains scheduled") {
+		return nil, errNoBoard
+	}
+	return entries, nil
+}
+
+func (s *Scraper) publishStationBoard(ctx context.Context, stationCode string, entries []BoardEntry) {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	board := publisher.StationBoard{
+		EventType:   "station_board",
+		StationCode: stationCode,
+		WindowHours: s.config().StationBoardHours,
+		Trains:      make([]publisher.StationBoardTrain, 0, len(entries)),
+		Timestamp:   now,
+	}
+	for _, e := range entries {
+		board.Trains = append(board.Trains, publisher.StationBoardTrain{
+			TrainNumber:        e.TrainNumber,
+			TrainName:          e.TrainName,
+			ScheduledArrival:   e.ScheduledArrival,
+			ExpectedArrival:    e.ExpectedArrival,
+			ScheduledDeparture: e.ScheduledDeparture,
+			ExpectedDeparture:  e.ExpectedDeparture,
+			DelayMinutes:       e.DelayMin,
+			Platform:           e.Platform,
+		})
+	}
+
+	if err := s.pub.PublishStationBoard(ctx, board); err != nil {
+		log.Printf("Failed to publish station board for %s: %v", stationCode, err)
+		return
+	}
+	log.Printf("[REAL] Station board %s: %d trains in next %dh", stationCode, len(entries), s.config().StationBoardHours)
+
+	for _, e := range entries {
+		if e.Platform == "" {
+			continue
+		}
+		ev := publisher.ExpectedPlatform{
+			EventType:         "expected_platform",
+			StationCode:       stationCode,
+			TrainNumber:       e.TrainNumber,
+			PlatformNumber:    e.Platform,
+			ExpectedArrival:   e.ExpectedArrival,
+			ExpectedDeparture: e.ExpectedDeparture,
+			DelayMinutes:      e.DelayMin,
+			Timestamp:         now,
+		}
+		if err := s.pub.PublishExpectedPlatform(ctx, ev); err != nil {
+			log.Printf("Failed to publish expected platform for %s at %s: %v", e.TrainNumber, stationCode, err)
+		}
+	}
+}
+
+func dashToEmpty(s string) string {
+	if s == "--" {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rail-app/ingestion/internal/fakentes"
+)
+
+func TestParseStationBoard(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    string
+		want    int
+		wantErr error
+	}{
+		{
+			name: "board",
+			page: "<div class='liveStation'>\n<div>Live Station HWH, next 4 hours</div>\n" +
+				"<div>12301 Howrah Rajdhani | Sch Arr: -- | Exp Arr: -- | Sch Dep: 16:50 | Exp Dep: 16:55 | Delay: 00:05 | PF 9</div>\n</div>",
+			want: 1,
+		},
+		{
+			name: "empty window",
+			page: "<div class='liveStation'>\n<div>Live Station HWH, next 4 hours</div>\n" +
+				"<div>No trains scheduled in the selected window.</div>\n</div>",
+		},
+		{name: "no board", page: "<html><body>Service Unavailable</body></html>", wantErr: errNoBoard},
+		{
+			name: "garbled",
+			page: "<div class='liveStation'>\n<div>Live Station HWH, next 4 hours</div>\n" +
+				"<div>12301 Howrah Rajdhani | Sch Arr? -- | Exp Arr? -- | Sch D",
+			wantErr: errNoBoard,
+		},
+	}
+
+	for _, tt := range tests {
+		entries, err := parseStationBoard(tt.page)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if len(entries) != tt.want {
+			t.Errorf("%s: got %d entries, want %d", tt.name, len(entries), tt.want)
+		}
+	}
+}
+
+// A deployment that polls boards but selects no trains still opens, and
+// reopens, its own NTES session.
+func TestPollStationBoardsWithoutTrains(t *testing.T) {
+	s, fake := newFakeNTES(t, fakentes.Options{})
+	rec := &recorder{}
+	s.pub = rec
+	s.config().StationBoardStations = []string{"HWH"}
+	ctx := context.Background()
+
+	s.pollStationBoards(ctx, ctx)
+	if len(rec.boards) != 1 {
+		t.Fatalf("published %d boards without an open session, want 1", len(rec.boards))
+	}
+
+	fake.ExpireSessions()
+	s.pollStationBoards(ctx, ctx)
+	if len(rec.boards) != 2 {
+		t.Fatalf("published %d boards after the session expired, want 2", len(rec.boards))
+	}
+	if err := s.NTESSession(); err != nil {
+		t.Errorf("NTESSession() = %v after reopening, want nil", err)
+	}
+}
+
+func TestPollStationBoardsKeepsBoardOnGarbledPage(t *testing.T) {
+	s, _ := newFakeNTES(t, fakentes.Options{MalformedRate: 1})
+	rec := &recorder{}
+	s.pub = rec
+	s.config().StationBoardStations = []string{"HWH"}
+	ctx := context.Background()
+
+	s.pollStationBoards(ctx, ctx)
+	if len(rec.boards) != 0 {
+		t.Errorf("published %+v from a garbled page, want nothing", rec.boards)
+	}
+}
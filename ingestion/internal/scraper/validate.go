@@ -0,0 +1,196 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rail-app/ingestion/internal/publisher"
+)
+
+// Quarantine reason codes.
+const (
+	ReasonUnknownStation  = "unknown_station"
+	ReasonOutOfOrder      = "out_of_order"
+	ReasonTimeBackwards   = "time_backwards"
+	ReasonImplausibleTime = "implausible_time"
+	ReasonDelayOutOfRange = "delay_out_of_range"
+	ReasonUnparseableTime = "unparseable_time"
+)
+
+const (
+	// timeToleranceMinutes is how far a reported time may sit from the
+	// scheduled time plus the reported delay.
+	timeToleranceMinutes = 90
+	minutesPerDay        = 24 * 60
+	maxRunDays           = 4
+)
+
+// An arrival at a stop comes before the departure from it.
+const (
+	phaseArrival = iota
+	phaseDeparture
+)
+
+type rejectedEvent struct {
+	Event  RunningEvent
+	Reason string
+	Detail string
+}
+
+// validateEvents checks scraped events against the train's timetable and
+// splits them into those fit to publish and those to quarantine. Events are
+// checked in order and compared with the last accepted one, so a single bad
+// line does not take the rest of the run down with it.
+//
+// An event at a station off the route is accepted when the station is in
+// known, which holds the off-route stations with coordinates in stations:
+// the train has been diverted there and can still be positioned. With no
+// timetable for such a stop only the delay and time format are checked, and
+// it does not move the point later events are ordered against. Other
+// off-route stations are rejected as unknown.
+func validateEvents(events []RunningEvent, route []RouteStop, known map[string]bool, maxDelay int) ([]RunningEvent, []rejectedEvent) {
+	var valid []RunningEvent
+	var rejected []rejectedEvent
+
+	reject := func(ev RunningEvent, reason, format string, args ...interface{}) {
+		rejected = append(rejected, rejectedEvent{Event: ev, Reason: reason, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	stopIdx := make(map[string]int, len(route))
+	for i, stop := range route {
+		stopIdx[stop.StationCode] = i
+	}
+
+	lastIdx, lastPhase, lastAbs := -1, -1, -1
+	for _, ev := range events {
+		if maxDelay > 0 && (ev.DelayMin < 0 || ev.DelayMin > maxDelay) {
+			reject(ev, ReasonDelayOutOfRange, "delay %d min outside 0..%d", ev.DelayMin, maxDelay)
+			continue
+		}
+
+		tod, ok := parseClock(ev.Time)
+		if !ok {
+			reject(ev, ReasonUnparseableTime, "time %q", ev.Time)
+			continue
+		}
+
+		// Without a timetable only the checks above are possible.
+		if len(route) == 0 {
+			valid = append(valid, ev)
+			continue
+		}
+
+		idx, ok := stopIdx[ev.StationCode]
+		if !ok {
+			if known[ev.StationCode] {
+				valid = append(valid, ev)
+			} else {
+				reject(ev, ReasonUnknownStation, "%s is not on the route", ev.StationCode)
+			}
+			continue
+		}
+
+		phase := eventPhase(ev)
+		if idx < lastIdx || (idx == lastIdx && phase <= lastPhase) {
+			reject(ev, ReasonOutOfOrder, "stop %d %s after stop %d", idx+1, ev.Type, lastIdx+1)
+			continue
+		}
+
+		abs, ok := absoluteMinutes(route[idx], phase, tod, ev.DelayMin)
+		if !ok {
+			reject(ev, ReasonImplausibleTime, "%s at %s is more than %d min from schedule plus delay",
+				ev.Type, ev.Time, timeToleranceMinutes)
+			continue
+		}
+		if abs < lastAbs {
+			reject(ev, ReasonTimeBackwards, "%s at %s is earlier than the previous event", ev.Type, ev.Time)
+			continue
+		}
+
+		valid = append(valid, ev)
+		lastIdx, lastPhase, lastAbs = idx, phase, abs
+	}
+
+	return valid, rejected
+}
+
+func eventPhase(ev RunningEvent) int {
+	if ev.Type == "Arrived" {
+		return phaseArrival
+	}
+	return phaseDeparture
+}
+
+// absoluteMinutes places a reported time of day on the run's timeline
+// (minutes since midnight of day 1), choosing the day that lands closest to
+// the scheduled time plus the reported delay. It fails when no day gets
+// within the tolerance.
+func absoluteMinutes(stop RouteStop, phase, tod, delay int) (int, bool) {
+	sched := stop.DepartureTime
+	if phase == phaseArrival || !sched.Valid {
+		sched = stop.ArrivalTime
+	}
+	if !sched.Valid {
+		sched = stop.DepartureTime
+	}
+	schedTod, ok := parseClock(sched.String)
+	if !ok {
+		return 0, false
+	}
+
+	day := stop.DayNumber
+	if day < 1 {
+		day = 1
+	}
+	expected := (day-1)*minutesPerDay + schedTod + delay
+
+	best, bestDiff := 0, -1
+	for d := 0; d <= maxRunDays+delay/minutesPerDay; d++ {
+		candidate := d*minutesPerDay + tod
+		diff := candidate - expected
+		if diff < 0 {
+			diff = -diff
+		}
+		if bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = candidate, diff
+		}
+	}
+	return best, bestDiff <= timeToleranceMinutes
+}
+
+// parseClock reads "HH:MM" or "HH:MM:SS" as minutes since midnight.
+func parseClock(s string) (int, bool) {
+	if len(s) > 5 {
+		s = s[:5]
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+func (s *Scraper) quarantineEvents(ctx context.Context, train TrainInfo, source string, rejected []rejectedEvent) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, r := range rejected {
+		ev := publisher.QuarantinedEvent{
+			TrainNumber:  train.Number,
+			Source:       source,
+			Reason:       r.Reason,
+			Detail:       r.Detail,
+			EventType:    r.Event.Type,
+			StationCode:  r.Event.StationCode,
+			StationName:  r.Event.StationName,
+			EventTime:    r.Event.Time,
+			DelayMinutes: r.Event.DelayMin,
+			Platform:     r.Event.Platform,
+			Timestamp:    now,
+		}
+		if err := s.pub.PublishQuarantinedEvent(ctx, ev); err != nil {
+			log.Printf("Failed to quarantine event for %s: %v", train.Number, err)
+		}
+	}
+	log.Printf("Quarantined %d event(s) for %s (%s)", len(rejected), train.Number, train.Name)
+}
@@ -0,0 +1,99 @@
+package scraper
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestValidateEvents(t *testing.T) {
+	at := func(hhmm string) sql.NullString { return sql.NullString{String: hhmm, Valid: true} }
+	route := []RouteStop{
+		{StationCode: "NDLS", StopNumber: 1, DepartureTime: at("06:00"), DayNumber: 1},
+		{StationCode: "MTJ", StopNumber: 2, ArrivalTime: at("08:00"), DepartureTime: at("08:05"), DayNumber: 1},
+		{StationCode: "AGC", StopNumber: 3, ArrivalTime: at("09:00"), DepartureTime: at("09:05"), DayNumber: 1},
+		{StationCode: "GWL", StopNumber: 4, ArrivalTime: at("11:00"), DayNumber: 1},
+	}
+	known := map[string]bool{"AF": true}
+
+	departed := func(code, hhmm string, delay int) RunningEvent {
+		return RunningEvent{Type: "Departed", StationCode: code, Time: hhmm, DelayMin: delay}
+	}
+	arrived := func(code, hhmm string, delay int) RunningEvent {
+		return RunningEvent{Type: "Arrived", StationCode: code, Time: hhmm, DelayMin: delay}
+	}
+
+	tests := []struct {
+		name   string
+		events []RunningEvent
+		want   []string // reason of each event, "" where accepted
+	}{
+		{
+			name:   "on schedule",
+			events: []RunningEvent{departed("NDLS", "06:00", 0), arrived("MTJ", "08:10", 10), departed("MTJ", "08:15", 10)},
+			want:   []string{"", "", ""},
+		},
+		{
+			name:   "unknown station",
+			events: []RunningEvent{departed("NDLS", "06:00", 0), arrived("XYZ", "07:00", 0)},
+			want:   []string{"", ReasonUnknownStation},
+		},
+		{
+			name:   "off route with coordinates",
+			events: []RunningEvent{departed("NDLS", "06:00", 0), departed("AF", "09:10", 20), arrived("GWL", "11:20", 20)},
+			want:   []string{"", "", ""},
+		},
+		{
+			name:   "out of order",
+			events: []RunningEvent{arrived("AGC", "09:00", 0), arrived("MTJ", "08:00", 0)},
+			want:   []string{"", ReasonOutOfOrder},
+		},
+		{
+			name:   "departure before arrival",
+			events: []RunningEvent{departed("MTJ", "08:05", 0), arrived("MTJ", "08:00", 0)},
+			want:   []string{"", ReasonOutOfOrder},
+		},
+		{
+			name:   "time backwards",
+			events: []RunningEvent{departed("NDLS", "07:20", 80), arrived("MTJ", "07:00", 0)},
+			want:   []string{"", ReasonTimeBackwards},
+		},
+		{
+			name:   "implausible time",
+			events: []RunningEvent{departed("NDLS", "14:00", 0)},
+			want:   []string{ReasonImplausibleTime},
+		},
+		{
+			name:   "delay out of range",
+			events: []RunningEvent{departed("NDLS", "06:00", -5), departed("NDLS", "06:00", 2000)},
+			want:   []string{ReasonDelayOutOfRange, ReasonDelayOutOfRange},
+		},
+		{
+			name:   "unparseable time",
+			events: []RunningEvent{departed("NDLS", "6 AM", 0)},
+			want:   []string{ReasonUnparseableTime},
+		},
+	}
+
+	for _, tt := range tests {
+		valid, rejected := validateEvents(tt.events, route, known, 1440)
+
+		var got []string
+		v, r := 0, 0
+		for _, ev := range tt.events {
+			switch {
+			case v < len(valid) && valid[v] == ev:
+				got = append(got, "")
+				v++
+			case r < len(rejected) && rejected[r].Event == ev:
+				got = append(got, rejected[r].Reason)
+				r++
+			default:
+				t.Fatalf("%s: event %+v neither accepted nor rejected in order", tt.name, ev)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: reasons = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
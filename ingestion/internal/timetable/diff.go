@@ -0,0 +1,136 @@
+package timetable
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Change kinds, as stored in timetable_changes.change_type.
+const (
+	ChangeStopAdded    = "stop_added"
+	ChangeStopRemoved  = "stop_removed"
+	ChangeStopModified = "stop_modified"
+)
+
+type Change struct {
+	Kind        string
+	StationCode string
+	StopNumber  int
+	Field       string
+	Old         string
+	New         string
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeStopAdded:
+		return fmt.Sprintf("+ stop %d %s", c.StopNumber, c.StationCode)
+	case ChangeStopRemoved:
+		return fmt.Sprintf("- stop %d %s", c.StopNumber, c.StationCode)
+	default:
+		return fmt.Sprintf("~ stop %d %s %s: %q -> %q", c.StopNumber, c.StationCode, c.Field, c.Old, c.New)
+	}
+}
+
+// Diff compares the stored schedule with the upstream one. Stops are matched
+// by station code so an inserted stop shows up as one addition plus
+// renumbering rather than a cascade of field changes. A route that calls at
+// a station twice, such as one that reverses or loops, has its visits
+// matched in order.
+func Diff(current, upstream *Schedule) []Change {
+	var changes []Change
+
+	curKeys := stopKeys(current.Stops)
+	cur := make(map[string]Stop, len(current.Stops))
+	for i, stop := range current.Stops {
+		cur[curKeys[i]] = stop
+	}
+	upKeys := stopKeys(upstream.Stops)
+	up := make(map[string]bool, len(upstream.Stops))
+
+	for i, next := range upstream.Stops {
+		up[upKeys[i]] = true
+		prev, ok := cur[upKeys[i]]
+		if !ok {
+			changes = append(changes, Change{
+				Kind:        ChangeStopAdded,
+				StationCode: next.StationCode,
+				StopNumber:  next.StopNumber,
+				New:         describeStop(next),
+			})
+			continue
+		}
+		changes = append(changes, diffStop(prev, next)...)
+	}
+
+	for i, prev := range current.Stops {
+		if !up[curKeys[i]] {
+			changes = append(changes, Change{
+				Kind:        ChangeStopRemoved,
+				StationCode: prev.StationCode,
+				StopNumber:  prev.StopNumber,
+				Old:         describeStop(prev),
+			})
+		}
+	}
+
+	return changes
+}
+
+// stopKeys identifies each stop by its station code and how many times the
+// route has called there before: "NDLS", then "NDLS#2" on a second visit.
+func stopKeys(stops []Stop) []string {
+	keys := make([]string, len(stops))
+	visits := make(map[string]int, len(stops))
+	for i, stop := range stops {
+		visits[stop.StationCode]++
+		keys[i] = stop.StationCode
+		if n := visits[stop.StationCode]; n > 1 {
+			keys[i] += "#" + strconv.Itoa(n)
+		}
+	}
+	return keys
+}
+
+func diffStop(prev, next Stop) []Change {
+	var changes []Change
+	field := func(name, old, new string) {
+		if old != new {
+			changes = append(changes, Change{
+				Kind:        ChangeStopModified,
+				StationCode: next.StationCode,
+				StopNumber:  next.StopNumber,
+				Field:       name,
+				Old:         old,
+				New:         new,
+			})
+		}
+	}
+
+	field("stop_number", strconv.Itoa(prev.StopNumber), strconv.Itoa(next.StopNumber))
+	field("arrival_time", prev.Arrival, next.Arrival)
+	field("departure_time", prev.Departure, next.Departure)
+	field("day_number", strconv.Itoa(prev.DayNumber), strconv.Itoa(next.DayNumber))
+	field("distance_from_source", strconv.Itoa(prev.Distance), strconv.Itoa(next.Distance))
+	field("halt_minutes", strconv.Itoa(prev.HaltMinutes), strconv.Itoa(next.HaltMinutes))
+	return changes
+}
+
+func describeStop(s Stop) string {
+	parts := []string{
+		"arr=" + orDash(s.Arrival),
+		"dep=" + orDash(s.Departure),
+		"day=" + strconv.Itoa(s.DayNumber),
+		"km=" + strconv.Itoa(s.Distance),
+		"halt=" + strconv.Itoa(s.HaltMinutes),
+	}
+	return strings.Join(parts, " ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
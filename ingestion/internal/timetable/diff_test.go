@@ -0,0 +1,74 @@
+package timetable
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	hwh := Stop{StationCode: "HWH", StopNumber: 1, Departure: "16:50", DayNumber: 1}
+	asn := Stop{StationCode: "ASN", StopNumber: 2, Arrival: "18:57", Departure: "18:59", HaltMinutes: 2, DayNumber: 1, Distance: 200}
+	dhn := Stop{StationCode: "DHN", StopNumber: 3, Arrival: "19:55", DayNumber: 1, Distance: 259}
+	renumber := func(s Stop, n int) Stop { s.StopNumber = n; return s }
+
+	tests := []struct {
+		name     string
+		current  []Stop
+		upstream []Stop
+		want     []Change
+	}{
+		{name: "unchanged", current: []Stop{hwh, asn, dhn}, upstream: []Stop{hwh, asn, dhn}},
+		{
+			name:     "stop added",
+			current:  []Stop{hwh, renumber(dhn, 2)},
+			upstream: []Stop{hwh, asn, dhn},
+			want: []Change{
+				{Kind: ChangeStopAdded, StationCode: "ASN", StopNumber: 2, New: "arr=18:57 dep=18:59 day=1 km=200 halt=2"},
+				{Kind: ChangeStopModified, StationCode: "DHN", StopNumber: 3, Field: "stop_number", Old: "2", New: "3"},
+			},
+		},
+		{
+			name:     "stop removed",
+			current:  []Stop{hwh, asn, dhn},
+			upstream: []Stop{hwh, renumber(dhn, 2)},
+			want: []Change{
+				{Kind: ChangeStopModified, StationCode: "DHN", StopNumber: 2, Field: "stop_number", Old: "3", New: "2"},
+				{Kind: ChangeStopRemoved, StationCode: "ASN", StopNumber: 2, Old: "arr=18:57 dep=18:59 day=1 km=200 halt=2"},
+			},
+		},
+		{
+			name:     "time changed",
+			current:  []Stop{hwh, asn},
+			upstream: []Stop{{StationCode: "HWH", StopNumber: 1, Departure: "17:00", DayNumber: 1}, asn},
+			want: []Change{
+				{Kind: ChangeStopModified, StationCode: "HWH", StopNumber: 1, Field: "departure_time", Old: "16:50", New: "17:00"},
+			},
+		},
+		{
+			// A loop back through ASN: each visit is matched with its own.
+			name: "station visited twice",
+			current: []Stop{hwh, asn, dhn,
+				{StationCode: "ASN", StopNumber: 4, Arrival: "21:00", DayNumber: 1, Distance: 318}},
+			upstream: []Stop{hwh, asn, dhn,
+				{StationCode: "ASN", StopNumber: 4, Arrival: "21:10", DayNumber: 1, Distance: 318}},
+			want: []Change{
+				{Kind: ChangeStopModified, StationCode: "ASN", StopNumber: 4, Field: "arrival_time", Old: "21:00", New: "21:10"},
+			},
+		},
+		{
+			name:     "second visit added",
+			current:  []Stop{hwh, asn, dhn},
+			upstream: []Stop{hwh, asn, dhn, {StationCode: "ASN", StopNumber: 4, Arrival: "21:00", DayNumber: 1, Distance: 318}},
+			want: []Change{
+				{Kind: ChangeStopAdded, StationCode: "ASN", StopNumber: 4, New: "arr=21:00 dep=- day=1 km=318 halt=0"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got := Diff(&Schedule{Stops: tt.current}, &Schedule{Stops: tt.upstream})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package timetable
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ERailSource reads schedules from eRail's TRAINROUTE endpoint, the same
+// one the scraper falls back to for running status.
+type ERailSource struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewERailSource() *ERailSource {
+	return &ERailSource{
+		BaseURL:    "https://erail.in",
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (e *ERailSource) Name() string { return "erail" }
+
+func (e *ERailSource) FetchSchedule(ctx context.Context, trainNumber string) (*Schedule, error) {
+	erailURL := fmt.Sprintf(
+		"%s/data.aspx?Action=TRAINROUTE&Password=2012&Data1=%s&Data2=0&Cache=true",
+		e.BaseURL, trainNumber,
+	)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", erailURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create erail request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := e.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erail request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("erail returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read erail body: %w", err)
+	}
+
+	sched := parseERailSchedule(trainNumber, string(body))
+	if len(sched.Stops) < 2 {
+		return nil, ErrNotFound
+	}
+	return sched, nil
+}
+
+// parseERailSchedule reads the ~-delimited route listing:
+// StationCode~StationName~Arrival~Departure~Day~Distance~...
+func parseERailSchedule(trainNumber, data string) *Schedule {
+	sched := &Schedule{TrainNumber: trainNumber}
+
+	segments := strings.Split(data, "~~~~~~~~")
+	if len(segments) < 2 {
+		segments = strings.Split(data, "~^")
+	}
+
+	for _, segment := range segments {
+		fields := strings.Split(strings.TrimSpace(segment), "~")
+		if len(fields) < 6 {
+			continue
+		}
+		code := strings.TrimSpace(fields[0])
+		if len(code) < 2 || len(code) > 6 {
+			continue
+		}
+
+		stop := Stop{
+			StationCode: code,
+			StationName: strings.TrimSpace(fields[1]),
+			StopNumber:  len(sched.Stops) + 1,
+			Arrival:     normaliseTime(fields[2]),
+			Departure:   normaliseTime(fields[3]),
+		}
+		stop.DayNumber, _ = strconv.Atoi(strings.TrimSpace(fields[4]))
+		if stop.DayNumber < 1 {
+			stop.DayNumber = 1
+		}
+		if km, err := strconv.ParseFloat(strings.TrimSpace(fields[5]), 64); err == nil {
+			stop.Distance = int(km)
+		}
+		stop.HaltMinutes = haltMinutes(stop.Arrival, stop.Departure)
+		sched.Stops = append(sched.Stops, stop)
+	}
+
+	return sched
+}
+
+// normaliseTime turns "16.55", "16:55" or "Source"/"Destination" into
+// "16:55" or "".
+func normaliseTime(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ".", ":")
+	if _, ok := minutesOfDay(s); !ok {
+		return ""
+	}
+	return s[:5]
+}
+
+const userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
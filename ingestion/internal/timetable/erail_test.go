@@ -0,0 +1,74 @@
+package timetable
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseERailSchedule(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []Stop
+	}{
+		{
+			name: "route",
+			data: "HWH~Howrah Jn~Source~16.50~1~0~~~~~~~~" +
+				"ASN~Asansol Jn~18.57~18.59~1~200.4~~~~~~~~" +
+				"NDLS~New Delhi~09.55~Destination~2~1451~",
+			want: []Stop{
+				{StationCode: "HWH", StationName: "Howrah Jn", StopNumber: 1, Departure: "16:50", DayNumber: 1},
+				{StationCode: "ASN", StationName: "Asansol Jn", StopNumber: 2, Arrival: "18:57", Departure: "18:59", HaltMinutes: 2, DayNumber: 1, Distance: 200},
+				{StationCode: "NDLS", StationName: "New Delhi", StopNumber: 3, Arrival: "09:55", DayNumber: 2, Distance: 1451},
+			},
+		},
+		{
+			name: "caret separated, halt over midnight",
+			data: "MAS~Chennai Central~Source~23.55~0~0~^SBC~Bengaluru~23.58~00.03~1~362~",
+			want: []Stop{
+				{StationCode: "MAS", StationName: "Chennai Central", StopNumber: 1, Departure: "23:55", DayNumber: 1},
+				{StationCode: "SBC", StationName: "Bengaluru", StopNumber: 2, Arrival: "23:58", Departure: "00:03", HaltMinutes: 5, DayNumber: 1, Distance: 362},
+			},
+		},
+		{
+			name: "short and malformed segments skipped",
+			data: "X~Bad~10.00~10.05~1~5~~~~~~~~" +
+				"AGC~Agra Cantt~10.00~10.05~1~n/a~~~~~~~~" +
+				"NOTE~only three~fields",
+			want: []Stop{
+				{StationCode: "AGC", StationName: "Agra Cantt", StopNumber: 1, Arrival: "10:00", Departure: "10:05", HaltMinutes: 5, DayNumber: 1},
+			},
+		},
+		{name: "empty", data: ""},
+	}
+
+	for _, tt := range tests {
+		got := parseERailSchedule("12301", tt.data)
+		if got.TrainNumber != "12301" {
+			t.Errorf("%s: TrainNumber = %q", tt.name, got.TrainNumber)
+		}
+		if !reflect.DeepEqual(got.Stops, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got.Stops, tt.want)
+		}
+	}
+}
+
+func TestNormaliseTime(t *testing.T) {
+	tests := map[string]string{
+		"16.55":       "16:55",
+		"16:55":       "16:55",
+		" 09.05 ":     "09:05",
+		"16:55:00":    "16:55",
+		"Source":      "",
+		"Destination": "",
+		"--":          "",
+		"":            "",
+		"24.00":       "",
+		"9.5":         "",
+	}
+	for in, want := range tests {
+		if got := normaliseTime(in); got != want {
+			t.Errorf("normaliseTime(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
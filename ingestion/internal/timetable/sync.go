@@ -0,0 +1,229 @@
+package timetable
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+type Syncer struct {
+	db     *sql.DB
+	src    Source
+	dryRun bool
+	out    io.Writer
+}
+
+// NewSyncer returns a Syncer that applies changes from src to db. In dry-run
+// mode the diff is written to out and nothing is changed.
+func NewSyncer(db *sql.DB, src Source, dryRun bool, out io.Writer) *Syncer {
+	return &Syncer{db: db, src: src, dryRun: dryRun, out: out}
+}
+
+// SyncAll syncs every train in the trains table, pausing between trains to
+// stay within the upstream's rate limit. It returns the number of trains
+// whose timetable changed.
+func (s *Syncer) SyncAll(ctx context.Context, pause time.Duration) (int, error) {
+	numbers, err := s.trainNumbers(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list trains: %w", err)
+	}
+
+	changed := 0
+	for i, number := range numbers {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return changed, ctx.Err()
+			case <-time.After(pause):
+			}
+		}
+
+		changes, err := s.SyncTrain(ctx, number)
+		if err != nil {
+			log.Printf("Timetable sync failed for %s: %v", number, err)
+			continue
+		}
+		if len(changes) > 0 {
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// SyncTrain fetches one train's schedule, diffs it against train_routes and,
+// unless in dry-run mode, applies the changes.
+func (s *Syncer) SyncTrain(ctx context.Context, trainNumber string) ([]Change, error) {
+	upstream, err := s.src.FetchSchedule(ctx, trainNumber)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			log.Printf("No upstream schedule for %s from %s, leaving it unchanged", trainNumber, s.src.Name())
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fetch schedule: %w", err)
+	}
+
+	current, err := s.loadSchedule(ctx, trainNumber)
+	if err != nil {
+		return nil, fmt.Errorf("load schedule: %w", err)
+	}
+
+	changes := Diff(current, upstream)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	if s.dryRun {
+		fmt.Fprintf(s.out, "%s: %d change(s) from %s\n", trainNumber, len(changes), s.src.Name())
+		for _, c := range changes {
+			fmt.Fprintf(s.out, "  %s\n", c)
+		}
+		return changes, nil
+	}
+
+	if err := s.apply(ctx, current, upstream, changes); err != nil {
+		return nil, fmt.Errorf("apply changes: %w", err)
+	}
+	log.Printf("Timetable for %s updated from %s: %d change(s)", trainNumber, s.src.Name(), len(changes))
+	return changes, nil
+}
+
+func (s *Syncer) apply(ctx context.Context, current, sched *Schedule, changes []Change) error {
+	platforms := make(map[string]string, len(current.Stops))
+	for i, key := range stopKeys(current.Stops) {
+		platforms[key] = current.Stops[i].Platform
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Stops may reference stations we have never seen; add a bare row so
+	// the foreign key holds. Until its coordinates are filled in, the
+	// scraper publishes no position at the station.
+	for _, stop := range sched.Stops {
+		name := stop.StationName
+		if name == "" {
+			name = stop.StationCode
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO stations (code, name) VALUES ($1, $2)
+			ON CONFLICT (code) DO NOTHING
+		`, stop.StationCode, name); err != nil {
+			return fmt.Errorf("ensure station %s: %w", stop.StationCode, err)
+		}
+	}
+
+	// Rewrite the route wholesale: renumbering stops in place would collide
+	// with the (train_number, stop_number) unique constraint.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM train_routes WHERE train_number = $1`, sched.TrainNumber); err != nil {
+		return fmt.Errorf("delete route: %w", err)
+	}
+	keys := stopKeys(sched.Stops)
+	for i, stop := range sched.Stops {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO train_routes (train_number, station_code, stop_number, arrival_time, departure_time,
+				halt_minutes, distance_from_source, day_number, platform)
+			VALUES ($1, $2, $3, NULLIF($4, '')::time, NULLIF($5, '')::time, $6, $7, $8, NULLIF($9, ''))
+		`, sched.TrainNumber, stop.StationCode, stop.StopNumber, stop.Arrival, stop.Departure,
+			stop.HaltMinutes, stop.Distance, stop.DayNumber, platforms[keys[i]]); err != nil {
+			return fmt.Errorf("insert stop %d: %w", stop.StopNumber, err)
+		}
+	}
+
+	first, last := sched.Stops[0], sched.Stops[len(sched.Stops)-1]
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE trains
+		SET source_station = $2, destination_station = $3,
+			distance_km = $4, duration_minutes = NULLIF($5, 0), updated_at = NOW()
+		WHERE number = $1
+	`, sched.TrainNumber, first.StationCode, last.StationCode, sched.DistanceKm(), sched.DurationMinutes()); err != nil {
+		return fmt.Errorf("update train: %w", err)
+	}
+
+	for _, c := range changes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO timetable_changes (train_number, change_type, station_code, stop_number, field, old_value, new_value, source)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8)
+		`, sched.TrainNumber, c.Kind, c.StationCode, c.StopNumber, c.Field, c.Old, c.New, s.src.Name()); err != nil {
+			return fmt.Errorf("record change: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Run syncs every train once per interval until ctx is cancelled.
+func (s *Syncer) Run(ctx context.Context, interval, pause time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		changed, err := s.SyncAll(ctx, pause)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Timetable sync failed: %v", err)
+		} else if err == nil {
+			log.Printf("Timetable sync complete: %d train(s) changed", changed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Syncer) loadSchedule(ctx context.Context, trainNumber string) (*Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT tr.station_code, tr.stop_number,
+			   COALESCE(to_char(tr.arrival_time, 'HH24:MI'), ''),
+			   COALESCE(to_char(tr.departure_time, 'HH24:MI'), ''),
+			   COALESCE(tr.halt_minutes, 0), COALESCE(tr.day_number, 1),
+			   COALESCE(tr.distance_from_source, 0), COALESCE(tr.platform, '')
+		FROM train_routes tr
+		WHERE tr.train_number = $1
+		ORDER BY tr.stop_number ASC
+	`, trainNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sched := &Schedule{TrainNumber: trainNumber}
+	for rows.Next() {
+		var stop Stop
+		if err := rows.Scan(
+			&stop.StationCode, &stop.StopNumber,
+			&stop.Arrival, &stop.Departure,
+			&stop.HaltMinutes, &stop.DayNumber, &stop.Distance, &stop.Platform,
+		); err != nil {
+			return nil, err
+		}
+		sched.Stops = append(sched.Stops, stop)
+	}
+	return sched, rows.Err()
+}
+
+func (s *Syncer) trainNumbers(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT number FROM trains ORDER BY number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var numbers []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, rows.Err()
+}
@@ -0,0 +1,88 @@
+// Package timetable keeps the trains and train_routes tables in step with
+// the upstream schedule.
+//
+// A Source supplies each train's full schedule; the Syncer diffs it against
+// train_routes and applies the changes in a transaction, recording every
+// change in timetable_changes.
+package timetable
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by a Source that has no schedule for a train.
+var ErrNotFound = errors.New("schedule not found")
+
+type Stop struct {
+	StationCode string
+	StationName string
+	StopNumber  int
+	Arrival     string // "HH:MM", empty at the source station
+	Departure   string // "HH:MM", empty at the destination station
+	HaltMinutes int
+	DayNumber   int
+	Distance    int
+	// Platform is only known for stored schedules; upstream schedules leave
+	// it empty and the stored value is kept.
+	Platform string
+}
+
+type Schedule struct {
+	TrainNumber string
+	Stops       []Stop
+}
+
+// Source fetches a train's full schedule from an upstream provider.
+type Source interface {
+	Name() string
+	FetchSchedule(ctx context.Context, trainNumber string) (*Schedule, error)
+}
+
+// DistanceKm is the distance from source to destination.
+func (s *Schedule) DistanceKm() int {
+	if len(s.Stops) == 0 {
+		return 0
+	}
+	return s.Stops[len(s.Stops)-1].Distance
+}
+
+// DurationMinutes is the end-to-end running time, or 0 if the schedule is
+// missing the departure or arrival time it needs.
+func (s *Schedule) DurationMinutes() int {
+	if len(s.Stops) < 2 {
+		return 0
+	}
+	first, last := s.Stops[0], s.Stops[len(s.Stops)-1]
+	dep, ok1 := minutesOfDay(first.Departure)
+	arr, ok2 := minutesOfDay(last.Arrival)
+	if !ok1 || !ok2 {
+		return 0
+	}
+	return (last.DayNumber-first.DayNumber)*24*60 + arr - dep
+}
+
+func minutesOfDay(hhmm string) (int, bool) {
+	if len(hhmm) < 5 || hhmm[2] != ':' {
+		return 0, false
+	}
+	h := int(hhmm[0]-'0')*10 + int(hhmm[1]-'0')
+	m := int(hhmm[3]-'0')*10 + int(hhmm[4]-'0')
+	if h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+// haltMinutes derives the halt at a stop from its arrival and departure.
+func haltMinutes(arrival, departure string) int {
+	arr, ok1 := minutesOfDay(arrival)
+	dep, ok2 := minutesOfDay(departure)
+	if !ok1 || !ok2 {
+		return 0
+	}
+	if dep < arr {
+		dep += 24 * 60
+	}
+	return dep - arr
+}
@@ -45,6 +45,11 @@ create_stream "train-positions"
 create_stream "platform-changes"
 create_stream "delay-events"
 create_stream "pnr-status-changes"
+create_stream "service-disruptions"
+create_stream "station-boards"
+create_stream "expected-platforms"
+create_stream "quarantined-events"
+create_stream "run-events"
 
 # Create monitoring/observability streams
 echo ""
@@ -58,10 +63,15 @@ echo ""
 echo "Parseable setup complete!"
 echo ""
 echo "Data streams:"
-echo "  train-positions:     train_number, latitude, longitude, speed_kmph, delay_minutes, current_station, next_station, eta_next, timestamp"
-echo "  platform-changes:    station_code, platform_number, train_number, event_type, timestamp"
-echo "  delay-events:        train_number, station_code, scheduled_time, actual_time, delay_minutes, cause, timestamp"
+echo "  train-positions:     train_number, latitude, longitude, speed_kmph, delay_minutes, current_station, next_station, eta_next, run_start_date, timestamp"
+echo "  platform-changes:    station_code, platform_number, train_number, event_type, run_start_date, timestamp"
+echo "  delay-events:        train_number, station_code, scheduled_time, actual_time, delay_minutes, cause, run_start_date, timestamp"
 echo "  pnr-status-changes:  pnr, old_status, new_status, coach, berth, timestamp"
+echo "  service-disruptions: train_number, disruption_type, affected_stations, new_departure_time, details, affected_journeys, timestamp"
+echo "  station-boards:      event_type, station_code, window_hours, trains, timestamp"
+echo "  expected-platforms:  event_type, station_code, train_number, platform_number, expected_arrival, expected_departure, delay_minutes, timestamp"
+echo "  quarantined-events:  train_number, source, reason, detail, event_type, station_code, station_name, event_time, delay_minutes, platform, timestamp"
+echo "  run-events:          train_number, event_type, station_code, scheduled_time, actual_time, delay_minutes, run_start_date, timestamp"
 echo ""
 echo "Monitoring streams:"
 echo "  app-logs:            service, level, message, context, trace_id, timestamp"